@@ -0,0 +1,213 @@
+// Copyright 2020 Alexander Zherdev. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package aclexport
+
+import (
+	"encoding"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strconv"
+
+	"github.com/mralexzee/acllibgo"
+)
+
+// CSVWriter writes slices of structs as CSV with the 'acl' rules applied
+type CSVWriter struct {
+	w *csv.Writer
+	options
+}
+
+// NewCSVWriter returns a writer writing to w
+// Without WithGroups or WithSubject rows are exported for an authenticated caller without groups.
+func NewCSVWriter(w io.Writer) *CSVWriter {
+	return &CSVWriter{w: csv.NewWriter(w)}
+}
+
+// WithGroups sets the groups the rows are exported for
+func (cw *CSVWriter) WithGroups(groups ...string) *CSVWriter {
+	cw.subject = acllibgo.Subject{Groups: groups}
+	return cw
+}
+
+// WithSubject sets the subject the rows are exported for
+func (cw *CSVWriter) WithSubject(subject acllibgo.Subject) *CSVWriter {
+	cw.subject = subject
+	return cw
+}
+
+// WithEngine evaluates the rules with the engine instead of the default one
+func (cw *CSVWriter) WithEngine(engine *acllibgo.Engine) *CSVWriter {
+	cw.engine = engine
+	return cw
+}
+
+// WithFields limits the columns to the fields the selector lists, as in Keep, by Go or JSON name
+func (cw *CSVWriter) WithFields(fields []acllibgo.StructField) *CSVWriter {
+	cw.fields = fields
+	return cw
+}
+
+// WithComma sets the field delimiter, ',' by default
+func (cw *CSVWriter) WithComma(comma rune) *CSVWriter {
+	cw.w.Comma = comma
+	return cw
+}
+
+// Write writes a header and a record for every struct of the slice or array items
+// The header lists the fields the subject may see, nested structs flattened with dotted JSON names
+// such as account.username, and embedded structs promoted. Fields depending on the object, such as
+// acl:"self", are not listed, masked fields are unless in omit mode. Slices and maps are written as
+// JSON, time.Time and text marshalers as text. A field referring back to a struct being flattened is
+// not listed.
+func (cw *CSVWriter) Write(items interface{}) error {
+	t, values, err := rows(items)
+	if err != nil {
+		return err
+	}
+
+	ev := cw.evaluator()
+	columns := csvColumns(ev, t, cw.fields, "", nil, map[reflect.Type]bool{})
+	header := make([]string, len(columns))
+	for x, c := range columns {
+		header[x] = c.header
+	}
+	if err := cw.w.Write(header); err != nil {
+		return err
+	}
+
+	c := newCopier(ev)
+	record := make([]string, len(columns))
+	for _, item := range values {
+		row := c.copy(item, cw.fields)
+		for x, column := range columns {
+			if record[x], err = column.cell(ev, row); err != nil {
+				return err
+			}
+		}
+		if err := cw.w.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+// csvColumn is a leaf field reached from the row struct through the FieldPlan indices
+type csvColumn struct {
+	header string
+	index  []int
+}
+
+// csvColumns lists the columns of the struct type the evaluator keeps without an object, or masks without omitting
+func csvColumns(ev *acllibgo.Evaluator, t reflect.Type, fields []acllibgo.StructField, prefix string, index []int, expanding map[reflect.Type]bool) []csvColumn {
+	expanding[t] = true
+	defer delete(expanding, t)
+
+	rv := []csvColumn{}
+	for _, f := range ev.Fields(t) {
+		if !f.Exported || f.JsonName == "-" {
+			continue
+		}
+
+		selected, nested := selectField(fields, f)
+		if !selected {
+			continue
+		}
+		if !ev.Kept(t, reflect.Value{}, f.Index) && (f.Omit || len(f.Mask) == 0 || f.Type.Kind() != reflect.String) {
+			continue
+		}
+
+		fieldIndex := append(append([]int{}, index...), f.Index)
+		if nestedType, ok := flattened(f.Type); ok {
+			if expanding[nestedType] {
+				continue
+			}
+			nestedPrefix := prefix + f.JsonName + "."
+			if f.Anonymous && !ev.Adapted(t) && !hasJsonName(t.Field(f.Index)) {
+				nestedPrefix = prefix
+			}
+			rv = append(rv, csvColumns(ev, nestedType, nested, nestedPrefix, fieldIndex, expanding)...)
+			continue
+		}
+
+		rv = append(rv, csvColumn{header: prefix + f.JsonName, index: fieldIndex})
+	}
+
+	return rv
+}
+
+// flattened returns the struct type of a struct or pointer to struct field whose fields become columns
+func flattened(t reflect.Type) (reflect.Type, bool) {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || isLeaf(t) {
+		return nil, false
+	}
+	return t, true
+}
+
+func hasJsonName(field reflect.StructField) bool {
+	tag := field.Tag.Get("json")
+	return len(tag) > 0 && tag[0] != ','
+}
+
+// cell returns the text of the column for the redacted row, empty when a pointer on the way is nil
+func (c csvColumn) cell(ev *acllibgo.Evaluator, row reflect.Value) (string, error) {
+	v := row
+	for _, i := range c.index {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return "", nil
+			}
+			v = v.Elem()
+		}
+		v = ev.Value(v.Type(), v, i)
+	}
+
+	return text(v)
+}
+
+// text formats a leaf value for a CSV cell
+func text(v reflect.Value) (string, error) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return "", nil
+		}
+		if v.Type().Implements(textMarshalerType) {
+			break
+		}
+		v = v.Elem()
+	}
+
+	if v.Type().Implements(textMarshalerType) {
+		data, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(data), err
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()), nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return base64.StdEncoding.EncodeToString(v.Bytes()), nil
+		}
+	}
+
+	data, err := json.Marshal(v.Interface())
+	return string(data), err
+}
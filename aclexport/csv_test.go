@@ -0,0 +1,181 @@
+// Copyright 2020 Alexander Zherdev. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package aclexport
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mralexzee/acllibgo"
+	"github.com/stretchr/testify/assert"
+)
+
+type User struct {
+	ID      int       `json:"id" xml:"id,attr"`
+	Name    string    `json:"name" xml:"name"`
+	Email   string    `json:"email" xml:"email,omitempty" acl:"admin,self"`
+	Salary  float64   `json:"salary" xml:"salary" acl:"hr"`
+	Tags    []string  `json:"tags" xml:"tag"`
+	Joined  time.Time `json:"joined" xml:"joined"`
+	Account *Account  `json:"account" xml:"account"`
+	Address Address   `json:"address" xml:"address"`
+	Manager *User     `json:"manager" xml:"-"`
+	Notes   string    `json:"-" xml:"-"`
+	Audit
+}
+
+type Account struct {
+	Username string `json:"username" xml:"username"`
+	Password string `json:"password" xml:"password" acl:"root"`
+}
+
+type Address struct {
+	City   string `json:"city" xml:"city"`
+	Street string `json:"street" xml:"street" acl:"admin"`
+}
+
+type Audit struct {
+	CreatedBy string `json:"createdBy" xml:"createdBy"`
+}
+
+var joined = time.Date(2020, 5, 1, 9, 30, 0, 0, time.UTC)
+
+func newUsers() []*User {
+	john := &User{
+		ID: 1, Name: "John", Email: "john@example.com", Salary: 1000.5, Tags: []string{"a", "b"}, Joined: joined,
+		Account: &Account{Username: "jdoe", Password: "secret"},
+		Address: Address{City: "Springfield", Street: "Main St"},
+		Notes:   "n",
+		Audit:   Audit{CreatedBy: "root"},
+	}
+	jane := &User{ID: 2, Name: "Jane, Jr.", Email: "jane@example.com", Joined: joined, Manager: john}
+	return []*User{john, nil, jane}
+}
+
+func writeCSV(t *testing.T, cw func(*CSVWriter) *CSVWriter, items interface{}) string {
+	buf := new(bytes.Buffer)
+	assert.NoError(t, cw(NewCSVWriter(buf)).Write(items))
+	return buf.String()
+}
+
+func Test_CSVWriter(t *testing.T) {
+
+	users := newUsers()
+	out := writeCSV(t, func(cw *CSVWriter) *CSVWriter { return cw.WithGroups("user") }, users)
+	assert.Equal(t, strings.Join([]string{
+		"id,name,tags,joined,account.username,address.city,createdBy",
+		`1,John,"[""a"",""b""]",2020-05-01T09:30:00Z,jdoe,Springfield,root`,
+		`2,"Jane, Jr.",null,2020-05-01T09:30:00Z,,,`,
+		"",
+	}, "\n"), out)
+	assert.Equal(t, newUsers(), users)
+
+	out = writeCSV(t, func(cw *CSVWriter) *CSVWriter { return cw.WithGroups("admin", "hr", "root") }, users)
+	assert.Equal(t, strings.Join([]string{
+		"id,name,email,salary,tags,joined,account.username,account.password,address.city,address.street,createdBy",
+		`1,John,john@example.com,1000.5,"[""a"",""b""]",2020-05-01T09:30:00Z,jdoe,secret,Springfield,Main St,root`,
+		`2,"Jane, Jr.",jane@example.com,0,null,2020-05-01T09:30:00Z,,,,,`,
+		"",
+	}, "\n"), out)
+}
+
+func Test_CSVWriter_Fields(t *testing.T) {
+
+	fields, err := acllibgo.Parse("name,Email,account(username),address")
+	assert.NoError(t, err)
+
+	out := writeCSV(t, func(cw *CSVWriter) *CSVWriter {
+		return cw.WithSubject(acllibgo.Subject{ID: "2"}).WithFields(fields).WithComma(';')
+	}, newUsers())
+	assert.Equal(t, "name;account.username;address.city\nJohn;jdoe;Springfield\nJane, Jr.;;\n", out)
+}
+
+func Test_CSVWriter_Mask(t *testing.T) {
+
+	e := acllibgo.NewEngine(acllibgo.Options{})
+	e.RegisterTypes(reflect.TypeOf(User{}))
+	p, err := acllibgo.ParsePolicy([]byte(`{"types": {"github.com/mralexzee/acllibgo/aclexport.User": {"fields": {"Email": {"mask": "***"}}}}}`))
+	assert.NoError(t, err)
+	assert.NoError(t, e.SetPolicy(p))
+
+	fields, _ := acllibgo.Parse("id,email")
+	out := writeCSV(t, func(cw *CSVWriter) *CSVWriter { return cw.WithEngine(e).WithFields(fields) }, []User{*newUsers()[0]})
+	assert.Equal(t, "id,email\n1,***\n", out)
+}
+
+func Test_CSVWriter_Omit(t *testing.T) {
+
+	e := acllibgo.NewEngine(acllibgo.Options{})
+	e.RegisterTypes(reflect.TypeOf(User{}))
+	p, err := acllibgo.ParsePolicy([]byte(`{"types": {"github.com/mralexzee/acllibgo/aclexport.User": {"fields": {"Email": {"mask": "***", "mode": "omit"}}}}}`))
+	assert.NoError(t, err)
+	assert.NoError(t, e.SetPolicy(p))
+
+	fields, _ := acllibgo.Parse("id,email")
+	out := writeCSV(t, func(cw *CSVWriter) *CSVWriter { return cw.WithEngine(e).WithFields(fields) }, []User{*newUsers()[0]})
+	assert.Equal(t, "id\n1\n", out)
+}
+
+func Test_CSVWriter_Errors(t *testing.T) {
+
+	buf := new(bytes.Buffer)
+	assert.EqualError(t, NewCSVWriter(buf).Write(nil), "aclexport: nil items")
+	assert.EqualError(t, NewCSVWriter(buf).Write(&User{}), "aclexport: expecting slice of structs, got aclexport.User")
+	assert.EqualError(t, NewCSVWriter(buf).Write([]int{1}), "aclexport: expecting slice of structs, got []int")
+	assert.Equal(t, 0, buf.Len())
+}
+
+// Ticket models a generated message, the internal fields precede the exported ones
+type Ticket struct {
+	state int
+	cache []byte
+
+	Number   string   `json:"number"`
+	Reporter string   `json:"reporter"`
+	Assignee *Account `json:"assignee"`
+}
+
+// ticketAdapter reads the rules of the Ticket fields from a map instead of tags
+type ticketAdapter struct {
+	acllibgo.StructAdapter
+	acls map[string]string
+}
+
+func (a ticketAdapter) Accepts(t reflect.Type) bool {
+	return t == reflect.TypeOf(Ticket{})
+}
+
+func (a ticketAdapter) Fields(t reflect.Type) []acllibgo.AdapterField {
+	rv := a.StructAdapter.Fields(t)
+	for x := range rv {
+		rv[x].Acl = a.acls[rv[x].JsonName]
+		rv[x].Mask = map[string]string{"reporter": "***"}[rv[x].JsonName]
+	}
+	return rv
+}
+
+func newTicketEngine() *acllibgo.Engine {
+	e := acllibgo.NewEngine(acllibgo.Options{})
+	e.RegisterAdapter(ticketAdapter{acls: map[string]string{"number": "public", "reporter": "support"}})
+	return e
+}
+
+func newTickets() []Ticket {
+	return []Ticket{{state: 1, cache: []byte{1}, Number: "T-1", Reporter: "john", Assignee: &Account{Username: "jdoe", Password: "secret"}}}
+}
+
+func Test_CSVWriter_Adapter(t *testing.T) {
+
+	tickets := newTickets()
+	out := writeCSV(t, func(cw *CSVWriter) *CSVWriter { return cw.WithEngine(newTicketEngine()).WithGroups("user") }, tickets)
+	assert.Equal(t, "number,reporter,assignee.username\nT-1,***,jdoe\n", out)
+
+	out = writeCSV(t, func(cw *CSVWriter) *CSVWriter { return cw.WithEngine(newTicketEngine()).WithGroups("support", "root") }, tickets)
+	assert.Equal(t, "number,reporter,assignee.username,assignee.password\nT-1,john,jdoe,secret\n", out)
+	assert.Equal(t, newTickets(), tickets)
+}
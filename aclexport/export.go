@@ -0,0 +1,202 @@
+// Copyright 2020 Alexander Zherdev. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package aclexport writes slices of structs as CSV and XML applying 'acl' rules, for downloads and reports
+//
+//	err := aclexport.NewCSVWriter(w).WithGroups("analyst").Write(users)
+//	err = aclexport.NewXMLWriter(w).WithGroups("analyst").WithRoot("users").Write(users)
+//
+// Rows are redacted like acllibgo.Project redacts a struct: denied fields are cleared, masked, or left out
+// in omit mode, nested structs, struct values included, get their own rules applied, and a Keep selector
+// set with WithFields limits the fields exported. The items are left untouched.
+package aclexport
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/mralexzee/acllibgo"
+)
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// options are the settings shared by the writers
+type options struct {
+	engine  *acllibgo.Engine
+	subject acllibgo.Subject
+	fields  []acllibgo.StructField
+}
+
+func (o *options) evaluator() *acllibgo.Evaluator {
+	if o.engine != nil {
+		return o.engine.NewEvaluator(o.subject)
+	}
+	return acllibgo.NewEvaluator(o.subject)
+}
+
+// rows returns the elements of a slice or array of structs or pointers to structs, nil elements skipped
+func rows(items interface{}) (reflect.Type, []reflect.Value, error) {
+	if items == nil {
+		return nil, nil, errors.New("aclexport: nil items")
+	}
+
+	v := reflect.ValueOf(items)
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, nil, errors.New("aclexport: expecting slice of structs, got " + v.Type().String())
+	}
+
+	t := v.Type().Elem()
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, nil, errors.New("aclexport: expecting slice of structs, got " + v.Type().String())
+	}
+
+	rv := make([]reflect.Value, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		item := v.Index(i)
+		if item.Kind() == reflect.Ptr {
+			if item.IsNil() {
+				continue
+			}
+			item = item.Elem()
+		}
+		rv = append(rv, item)
+	}
+
+	return t, rv, nil
+}
+
+// copier makes redacted copies of values, pointers shared in the source are shared in the copy
+type copier struct {
+	ev     *acllibgo.Evaluator
+	copies map[pointer]reflect.Value
+}
+
+type pointer struct {
+	addr uintptr
+	typ  reflect.Type
+}
+
+func newCopier(ev *acllibgo.Evaluator) *copier {
+	return &copier{ev: ev, copies: make(map[pointer]reflect.Value)}
+}
+
+// copy returns a copy of v with the rules and the selector applied to every struct, a nil selector selects all
+func (c *copier) copy(v reflect.Value, fields []acllibgo.StructField) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		key := pointer{addr: v.Pointer(), typ: v.Type()}
+		if rv, found := c.copies[key]; found {
+			return rv
+		}
+		rv := reflect.New(v.Type().Elem())
+		c.copies[key] = rv
+		rv.Elem().Set(c.copy(v.Elem(), fields))
+		return rv
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		rv := reflect.New(v.Type()).Elem()
+		rv.Set(c.copy(v.Elem(), fields))
+		return rv
+	case reflect.Struct:
+		if isLeaf(v.Type()) {
+			return v
+		}
+		return c.copyStruct(v, fields)
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		rv := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			rv.Index(i).Set(c.copy(v.Index(i), fields))
+		}
+		return rv
+	case reflect.Array:
+		rv := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			rv.Index(i).Set(c.copy(v.Index(i), fields))
+		}
+		return rv
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		rv := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			rv.SetMapIndex(iter.Key(), c.copy(iter.Value(), fields))
+		}
+		return rv
+	}
+
+	return v
+}
+
+// copyStruct copies the struct value, denied and unselected fields are set to their mask or zero value
+// The fields are read and written through the evaluator, so types walked by an adapter are copied too.
+func (c *copier) copyStruct(v reflect.Value, fields []acllibgo.StructField) reflect.Value {
+	t := v.Type()
+	rv := reflect.New(t).Elem()
+	rv.Set(v)
+
+	for _, f := range c.ev.Fields(t) {
+		if !f.Exported {
+			continue
+		}
+
+		selected, nested := selectField(fields, f)
+		switch {
+		case !selected:
+			c.ev.SetValue(t, rv, f.Index, reflect.Zero(f.Type))
+		case !c.ev.Kept(t, v, f.Index):
+			value := reflect.Zero(f.Type)
+			if len(f.Mask) > 0 && f.Type.Kind() == reflect.String {
+				value = reflect.ValueOf(f.Mask).Convert(f.Type)
+			}
+			c.ev.SetValue(t, rv, f.Index, value)
+		default:
+			c.ev.SetValue(t, rv, f.Index, c.copy(c.ev.Value(t, v, f.Index), nested))
+		}
+	}
+
+	return rv
+}
+
+// selectField reports whether the selector lists the field by Go or JSON name, and returns its nested selector
+func selectField(fields []acllibgo.StructField, f acllibgo.FieldPlan) (bool, []acllibgo.StructField) {
+	if fields == nil {
+		return true, nil
+	}
+	for _, k := range fields {
+		if k.Name == "*" || strings.EqualFold(k.Name, f.Name) || strings.EqualFold(k.Name, f.JsonName) {
+			return true, k.Fields
+		}
+	}
+	return false, nil
+}
+
+// isLeaf reports whether values of the struct type are exported as a whole, e.g. time.Time
+func isLeaf(t reflect.Type) bool {
+	return t == timeType || t.Implements(jsonMarshalerType) || t.Implements(textMarshalerType) ||
+		reflect.PtrTo(t).Implements(textMarshalerType)
+}
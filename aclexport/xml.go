@@ -0,0 +1,476 @@
+// Copyright 2020 Alexander Zherdev. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package aclexport
+
+import (
+	"encoding"
+	"encoding/xml"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/mralexzee/acllibgo"
+)
+
+var (
+	xmlMarshalerType     = reflect.TypeOf((*xml.Marshaler)(nil)).Elem()
+	xmlMarshalerAttrType = reflect.TypeOf((*xml.MarshalerAttr)(nil)).Elem()
+	xmlNameType          = reflect.TypeOf(xml.Name{})
+)
+
+// XMLWriter writes slices of structs as XML with the 'acl' rules applied
+type XMLWriter struct {
+	enc  *xml.Encoder
+	root string
+	options
+}
+
+// NewXMLWriter returns a writer writing to w, items are wrapped in an <items> root element
+// Without WithGroups or WithSubject items are exported for an authenticated caller without groups.
+func NewXMLWriter(w io.Writer) *XMLWriter {
+	return &XMLWriter{enc: xml.NewEncoder(w), root: "items"}
+}
+
+// WithGroups sets the groups the items are exported for
+func (xw *XMLWriter) WithGroups(groups ...string) *XMLWriter {
+	xw.subject = acllibgo.Subject{Groups: groups}
+	return xw
+}
+
+// WithSubject sets the subject the items are exported for
+func (xw *XMLWriter) WithSubject(subject acllibgo.Subject) *XMLWriter {
+	xw.subject = subject
+	return xw
+}
+
+// WithEngine evaluates the rules with the engine instead of the default one
+func (xw *XMLWriter) WithEngine(engine *acllibgo.Engine) *XMLWriter {
+	xw.engine = engine
+	return xw
+}
+
+// WithFields limits the exported fields to those the selector lists, as in Keep, by Go or JSON name
+// Fields left out are written with their zero value, or not at all when tagged omitempty.
+func (xw *XMLWriter) WithFields(fields []acllibgo.StructField) *XMLWriter {
+	xw.fields = fields
+	return xw
+}
+
+// WithRoot sets the name of the root element
+func (xw *XMLWriter) WithRoot(root string) *XMLWriter {
+	xw.root = root
+	return xw
+}
+
+// WithIndent indents the output, see xml.Encoder.Indent
+func (xw *XMLWriter) WithIndent(prefix string, indent string) *XMLWriter {
+	xw.enc.Indent(prefix, indent)
+	return xw
+}
+
+// Write writes the root element holding every struct of the slice or array items
+// Structs are written field by field following their 'xml' tags like encoding/xml, denied fields hold
+// their mask or zero value, or are left out in omit mode. Other values, and structs embedding unexported
+// struct types, are encoded with encoding/xml from a redacted copy, where denied fields are never left out.
+func (xw *XMLWriter) Write(items interface{}) error {
+	_, values, err := rows(items)
+	if err != nil {
+		return err
+	}
+
+	root := xml.StartElement{Name: xml.Name{Local: xw.root}}
+	if err := xw.enc.EncodeToken(root); err != nil {
+		return err
+	}
+
+	x := &xmlEncoder{enc: xw.enc, c: newCopier(xw.evaluator())}
+	for _, item := range values {
+		if err := x.encode(item, xw.fields, xml.Name{}, true); err != nil {
+			return err
+		}
+	}
+
+	if err := xw.enc.EncodeToken(root.End()); err != nil {
+		return err
+	}
+	return xw.enc.Flush()
+}
+
+// xmlEncoder writes values through the encoder, applying the rules to the structs it redacts
+type xmlEncoder struct {
+	enc *xml.Encoder
+	c   *copier
+}
+
+// xmlFieldKind is where a field is written, as set by the flags of its 'xml' tag
+type xmlFieldKind int
+
+const (
+	xmlElement xmlFieldKind = iota
+	xmlAttr
+	xmlCharData
+	xmlComment
+	xmlInnerXML
+)
+
+// xmlField is a struct field with its 'xml' tag parsed like encoding/xml does
+type xmlField struct {
+	name      xml.Name
+	parents   []string
+	kind      xmlFieldKind
+	omitEmpty bool
+}
+
+// xmlItem is a field value to write, the fields of untagged embedded structs are promoted
+type xmlItem struct {
+	field  xmlField
+	value  reflect.Value
+	fields []acllibgo.StructField
+	redact bool
+}
+
+// encode writes the value as an element named name, nil pointers are not written and slices write
+// an element per item. The rules apply to the value when redact is set, it is written as is otherwise.
+func (x *xmlEncoder) encode(v reflect.Value, fields []acllibgo.StructField, name xml.Name, redact bool) error {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	t := v.Type()
+	switch {
+	case isXMLLeaf(t):
+	case t.Kind() == reflect.Struct:
+		return x.encodeStruct(v, fields, name, redact)
+	case (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && t.Elem().Kind() != reflect.Uint8:
+		for i := 0; i < v.Len(); i++ {
+			if err := x.encode(v.Index(i), fields, name, redact); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if redact {
+		v = x.c.copy(v, fields)
+	}
+	return x.enc.EncodeElement(v.Interface(), xml.StartElement{Name: name})
+}
+
+// encodeStruct writes the struct value as an element, attributes first and then the other fields in order
+func (x *xmlEncoder) encodeStruct(v reflect.Value, fields []acllibgo.StructField, name xml.Name, redact bool) error {
+	start, err := xmlStart(v, name)
+	if err != nil {
+		return err
+	}
+	if hasUnexportedEmbedded(v.Type()) {
+		// the promoted fields cannot be read, encoding/xml writes a copy with them zeroed or masked
+		if redact {
+			v = x.c.copy(v, fields)
+		}
+		return x.enc.EncodeElement(v.Interface(), start)
+	}
+
+	items := x.items(v, fields, redact, nil)
+	for _, item := range items {
+		if item.field.kind != xmlAttr || (item.field.omitEmpty && isEmptyValue(item.value)) {
+			continue
+		}
+		if value, ok, err := xmlText(item.value, true); err != nil {
+			return err
+		} else if ok {
+			start.Attr = append(start.Attr, xml.Attr{Name: item.field.name, Value: value})
+		}
+	}
+	if err := x.enc.EncodeToken(start); err != nil {
+		return err
+	}
+
+	// parents are the elements opened for fields tagged a>b, shared by consecutive fields
+	parents := []string{}
+	for _, item := range items {
+		if item.field.kind == xmlAttr {
+			continue
+		}
+		if item.field.kind != xmlElement {
+			if parents, err = x.closeParents(parents, 0); err != nil {
+				return err
+			}
+			if err := x.encodeText(item); err != nil {
+				return err
+			}
+			continue
+		}
+
+		common := 0
+		for common < len(parents) && common < len(item.field.parents) && parents[common] == item.field.parents[common] {
+			common++
+		}
+		if parents, err = x.closeParents(parents, common); err != nil {
+			return err
+		}
+		if len(item.field.parents) > len(parents) && !isNil(item.value) {
+			for _, parent := range item.field.parents[len(parents):] {
+				if err := x.enc.EncodeToken(xml.StartElement{Name: xml.Name{Local: parent}}); err != nil {
+					return err
+				}
+				parents = append(parents, parent)
+			}
+		}
+
+		if item.field.omitEmpty && isEmptyValue(item.value) {
+			continue
+		}
+		if err := x.encode(item.value, item.fields, item.field.name, item.redact); err != nil {
+			return err
+		}
+	}
+
+	if _, err := x.closeParents(parents, 0); err != nil {
+		return err
+	}
+	return x.enc.EncodeToken(start.End())
+}
+
+// closeParents closes the parent elements past the first count ones
+func (x *xmlEncoder) closeParents(parents []string, count int) ([]string, error) {
+	for i := len(parents) - 1; i >= count; i-- {
+		if err := x.enc.EncodeToken(xml.EndElement{Name: xml.Name{Local: parents[i]}}); err != nil {
+			return nil, err
+		}
+	}
+	return parents[:count], nil
+}
+
+// encodeText writes a chardata, comment, or innerxml field, innerxml is decoded and written token by token
+func (x *xmlEncoder) encodeText(item xmlItem) error {
+	value, ok, err := xmlText(item.value, false)
+	if err != nil || !ok {
+		return err
+	}
+
+	switch item.field.kind {
+	case xmlCharData:
+		return x.enc.EncodeToken(xml.CharData(value))
+	case xmlComment:
+		if len(value) == 0 {
+			return nil
+		}
+		return x.enc.EncodeToken(xml.Comment(value))
+	}
+
+	dec := xml.NewDecoder(strings.NewReader(value))
+	for {
+		token, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := x.enc.EncodeToken(xml.CopyToken(token)); err != nil {
+			return err
+		}
+	}
+}
+
+// items lists the fields of the struct value to write with their redacted values
+// Fields the selector leaves out hold their zero value, denied fields their mask or zero value, and
+// denied fields in omit mode are not listed.
+func (x *xmlEncoder) items(v reflect.Value, fields []acllibgo.StructField, redact bool, rv []xmlItem) []xmlItem {
+	t := v.Type()
+	adapted := x.c.ev.Adapted(t)
+	for _, f := range x.c.ev.Fields(t) {
+		// the fields of adapted types are not struct fields, they have no tags
+		tag, anonymous := "", false
+		if !adapted {
+			sf := t.Field(f.Index)
+			tag, anonymous = sf.Tag.Get("xml"), sf.Anonymous
+		}
+		if !f.Exported || tag == "-" || f.Name == "XMLName" {
+			continue
+		}
+
+		fv := x.c.ev.Value(t, v, f.Index)
+		kept := redact
+		var nested []acllibgo.StructField
+		if redact {
+			var selected bool
+			selected, nested = selectField(fields, f)
+			switch {
+			case !selected:
+				fv, kept = reflect.Zero(f.Type), false
+			case !x.c.ev.Kept(t, v, f.Index):
+				if f.Omit {
+					continue
+				}
+				fv, kept = x.c.ev.Redacted(t, v, f.Index), false
+			}
+		}
+
+		if anonymous && len(tag) == 0 {
+			embedded := fv
+			if embedded.Kind() == reflect.Ptr && embedded.Type().Elem().Kind() == reflect.Struct {
+				if embedded.IsNil() {
+					continue
+				}
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				rv = x.items(embedded, nested, kept, rv)
+				continue
+			}
+		}
+
+		rv = append(rv, xmlItem{field: parseXMLTag(f.Name, tag), value: fv, fields: nested, redact: kept})
+	}
+
+	return rv
+}
+
+// parseXMLTag parses the 'xml' tag of the field, e.g. "ns name,attr,omitempty" or "a>b>name"
+func parseXMLTag(field string, tag string) xmlField {
+	rv := xmlField{}
+	tokens := strings.Split(tag, ",")
+	for _, flag := range tokens[1:] {
+		switch flag {
+		case "attr":
+			rv.kind = xmlAttr
+		case "chardata":
+			rv.kind = xmlCharData
+		case "comment":
+			rv.kind = xmlComment
+		case "innerxml":
+			rv.kind = xmlInnerXML
+		case "omitempty":
+			rv.omitEmpty = true
+		}
+	}
+
+	name := tokens[0]
+	if x := strings.LastIndex(name, " "); x >= 0 {
+		rv.name.Space, name = name[:x], name[x+1:]
+	}
+	if path := strings.Split(name, ">"); len(path) > 1 {
+		rv.parents, name = path[:len(path)-1], path[len(path)-1]
+	}
+	if len(name) == 0 {
+		name = field
+	}
+	rv.name.Local = name
+
+	return rv
+}
+
+// xmlStart returns the start element of the struct value, named after its XMLName field, the name of
+// the field holding it, or its type like encoding/xml
+func xmlStart(v reflect.Value, name xml.Name) (xml.StartElement, error) {
+	t := v.Type()
+	rv := xml.StartElement{}
+	if f, ok := t.FieldByName("XMLName"); ok {
+		tag := parseXMLTag(f.Name, f.Tag.Get("xml"))
+		if len(f.Tag.Get("xml")) > 0 && tag.name.Local != "XMLName" {
+			rv.Name = tag.name
+		} else if fv, err := v.FieldByIndexErr(f.Index); err == nil && fv.Type() == xmlNameType {
+			rv.Name = fv.Interface().(xml.Name)
+		}
+	}
+	if len(rv.Name.Local) == 0 {
+		rv.Name = name
+	}
+	if len(rv.Name.Local) == 0 {
+		rv.Name.Local = t.Name()
+		if x := strings.IndexByte(rv.Name.Local, '['); x >= 0 {
+			rv.Name.Local = rv.Name.Local[:x]
+		}
+	}
+	if len(rv.Name.Local) == 0 {
+		return rv, &xml.UnsupportedTypeError{Type: t}
+	}
+
+	return rv, nil
+}
+
+// hasUnexportedEmbedded reports whether the struct type embeds an unexported struct type
+func hasUnexportedEmbedded(t reflect.Type) bool {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if _, ok := flattened(f.Type); ok && f.Anonymous && len(f.PkgPath) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// isXMLLeaf reports whether values of the type are written by encoding/xml as a whole
+func isXMLLeaf(t reflect.Type) bool {
+	if t.Implements(xmlMarshalerType) || reflect.PtrTo(t).Implements(xmlMarshalerType) {
+		return true
+	}
+	return t.Kind() == reflect.Struct && isLeaf(t) || t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType)
+}
+
+// xmlText formats an attribute or character data value like encoding/xml, nil pointers are not written
+func xmlText(v reflect.Value, attr bool) (string, bool, error) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return "", false, nil
+		}
+		if attr && v.Type().Implements(xmlMarshalerAttrType) || v.Type().Implements(textMarshalerType) {
+			break
+		}
+		v = v.Elem()
+	}
+
+	if attr && v.Type().Implements(xmlMarshalerAttrType) {
+		a, err := v.Interface().(xml.MarshalerAttr).MarshalXMLAttr(xml.Name{})
+		return a.Value, len(a.Name.Local) > 0 || len(a.Value) > 0, err
+	}
+	if v.Type().Implements(textMarshalerType) {
+		data, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		return string(data), true, err
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), true, nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), true, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), true, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), 10), true, nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()), true, nil
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			data := make([]byte, v.Len())
+			reflect.Copy(reflect.ValueOf(data), v)
+			return string(data), true, nil
+		}
+	}
+
+	return "", false, &xml.UnsupportedTypeError{Type: v.Type()}
+}
+
+func isNil(v reflect.Value) bool {
+	return (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil()
+}
+
+// isEmptyValue reports whether encoding/xml leaves out the value of an omitempty field
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Interface, reflect.Ptr:
+		return v.IsZero()
+	}
+	return false
+}
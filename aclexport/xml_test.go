@@ -0,0 +1,90 @@
+// Copyright 2020 Alexander Zherdev. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package aclexport
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/mralexzee/acllibgo"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_XMLWriter(t *testing.T) {
+
+	users := newUsers()
+	buf := new(bytes.Buffer)
+	assert.NoError(t, NewXMLWriter(buf).WithGroups("user").WithRoot("users").Write(users))
+	assert.Equal(t, `<users>`+
+		`<User id="1"><name>John</name><salary>0</salary><tag>a</tag><tag>b</tag><joined>2020-05-01T09:30:00Z</joined>`+
+		`<account><username>jdoe</username><password></password></account>`+
+		`<address><city>Springfield</city><street></street></address><createdBy>root</createdBy></User>`+
+		`<User id="2"><name>Jane, Jr.</name><salary>0</salary><joined>2020-05-01T09:30:00Z</joined>`+
+		`<address><city></city><street></street></address><createdBy></createdBy></User>`+
+		`</users>`, buf.String())
+	assert.Equal(t, newUsers(), users)
+}
+
+func Test_XMLWriter_Subject(t *testing.T) {
+
+	fields, err := acllibgo.Parse("id,email,address(street)")
+	assert.NoError(t, err)
+
+	buf := new(bytes.Buffer)
+	assert.NoError(t, NewXMLWriter(buf).WithSubject(acllibgo.Subject{ID: "2", Groups: []string{"admin"}}).WithFields(fields).WithIndent("", " ").Write(newUsers()[2:]))
+	assert.Equal(t, "<items>\n"+
+		" <User id=\"2\">\n  <name></name>\n  <email>jane@example.com</email>\n  <salary>0</salary>\n  <joined>0001-01-01T00:00:00Z</joined>\n"+
+		"  <address>\n   <city></city>\n   <street></street>\n  </address>\n  <createdBy></createdBy>\n </User>\n"+
+		"</items>", buf.String())
+
+	assert.EqualError(t, NewXMLWriter(buf).Write(42), "aclexport: expecting slice of structs, got int")
+}
+
+type Badge struct {
+	Code   string `xml:"code,attr" acl:"admin" aclmode:"omit"`
+	Holder string `xml:"holder" acl:"admin" aclmode:"omit"`
+	Level  int    `xml:"level" acl:"admin"`
+	Issuer Issuer `xml:"issuer"`
+}
+
+type Issuer struct {
+	Name string `xml:"name" acl:"admin" aclmode:"omit"`
+}
+
+func Test_XMLWriter_Omit(t *testing.T) {
+
+	badges := []Badge{{Code: "b1", Holder: "John", Level: 3, Issuer: Issuer{Name: "hr"}}}
+	buf := new(bytes.Buffer)
+	assert.NoError(t, NewXMLWriter(buf).WithGroups("user").Write(badges))
+	assert.Equal(t, `<items><Badge><level>0</level><issuer></issuer></Badge></items>`, buf.String())
+
+	buf.Reset()
+	assert.NoError(t, NewXMLWriter(buf).WithGroups("admin").Write(badges))
+	assert.Equal(t, `<items><Badge code="b1"><holder>John</holder><level>3</level><issuer><name>hr</name></issuer></Badge></items>`, buf.String())
+
+	// policy omit mode takes precedence over the mask
+	e := acllibgo.NewEngine(acllibgo.Options{})
+	e.RegisterTypes(reflect.TypeOf(User{}))
+	p, err := acllibgo.ParsePolicy([]byte(`{"types": {"github.com/mralexzee/acllibgo/aclexport.User": {"fields": {"Email": {"mask": "***", "mode": "omit"}, "Salary": {"mode": "omit"}}}}}`))
+	assert.NoError(t, err)
+	assert.NoError(t, e.SetPolicy(p))
+
+	fields, _ := acllibgo.Parse("id,email,salary")
+	buf.Reset()
+	assert.NoError(t, NewXMLWriter(buf).WithEngine(e).WithFields(fields).Write(newUsers()[:1]))
+	assert.Equal(t, `<items><User id="1"><name></name><joined>0001-01-01T00:00:00Z</joined>`+
+		`<address><city></city><street></street></address><createdBy></createdBy></User></items>`, buf.String())
+}
+
+func Test_XMLWriter_Adapter(t *testing.T) {
+
+	tickets := newTickets()
+	buf := new(bytes.Buffer)
+	assert.NoError(t, NewXMLWriter(buf).WithEngine(newTicketEngine()).WithGroups("user").Write(tickets))
+	assert.Equal(t, `<items><Ticket><Number>T-1</Number><Reporter>***</Reporter>`+
+		`<Assignee><username>jdoe</username><password></password></Assignee></Ticket></items>`, buf.String())
+	assert.Equal(t, newTickets(), tickets)
+}
@@ -0,0 +1,614 @@
+// Copyright 2020 Alexander Zherdev. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package acljson encodes values to JSON applying 'acl' rules while writing
+//
+//	data, err := acljson.Marshal(&user, []string{"admin"})
+//	err = acljson.NewEncoder(w).WithGroups("admin").Encode(users)
+//
+// The output is byte-identical to scrubbing the value with acllibgo.Scrub and encoding it with
+// json.Marshal, but the value is walked once and left untouched.
+//
+// Denied fields tagged aclmode:"omit", or given the omit mode by a policy, are left out of the output
+// instead of being written with their zero value, the fields promoted from embedded structs included.
+// Structs encoding/json does not write member by member, those with a custom marshaler or quoted
+// fields, and types walked by an acllibgo.Adapter, are encoded from a scrubbed copy and keep such fields
+// zeroed.
+package acljson
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/mralexzee/acllibgo"
+)
+
+// Marshal returns the JSON encoding of v scrubbed for the groups, see acllibgo.Scrub
+func Marshal(v interface{}, groups []string) ([]byte, error) {
+	if groups == nil {
+		return nil, errors.New("acljson: nil acl")
+	}
+
+	s := newEncodeState(acllibgo.NewEvaluator(acllibgo.Subject{Groups: groups}))
+	if err := s.encode(v); err != nil {
+		return nil, err
+	}
+	return s.Bytes(), nil
+}
+
+// Encoder writes JSON values scrubbed for a subject to an output stream
+type Encoder struct {
+	w       io.Writer
+	engine  *acllibgo.Engine
+	subject acllibgo.Subject
+}
+
+// NewEncoder returns an encoder writing to w
+// Without WithGroups or WithSubject values are encoded for an authenticated caller without groups.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// WithGroups sets the groups the values are scrubbed for
+func (enc *Encoder) WithGroups(groups ...string) *Encoder {
+	enc.subject = acllibgo.Subject{Groups: groups}
+	return enc
+}
+
+// WithSubject sets the subject the values are scrubbed for, see acllibgo.ScrubSubject
+func (enc *Encoder) WithSubject(subject acllibgo.Subject) *Encoder {
+	enc.subject = subject
+	return enc
+}
+
+// WithEngine evaluates the rules with the engine instead of the default one
+func (enc *Encoder) WithEngine(engine *acllibgo.Engine) *Encoder {
+	enc.engine = engine
+	return enc
+}
+
+// Encode writes the JSON encoding of v followed by a newline, like json.Encoder.Encode
+func (enc *Encoder) Encode(v interface{}) error {
+	var ev *acllibgo.Evaluator
+	if enc.engine != nil {
+		ev = enc.engine.NewEvaluator(enc.subject)
+	} else {
+		ev = acllibgo.NewEvaluator(enc.subject)
+	}
+
+	s := newEncodeState(ev)
+	if err := s.encode(v); err != nil {
+		return err
+	}
+	s.WriteByte('\n')
+
+	_, err := enc.w.Write(s.Bytes())
+	return err
+}
+
+type encodeState struct {
+	bytes.Buffer
+	ev     *acllibgo.Evaluator
+	fields map[reflect.Type][]acllibgo.FieldPlan
+}
+
+func newEncodeState(ev *acllibgo.Evaluator) *encodeState {
+	return &encodeState{ev: ev, fields: make(map[reflect.Type][]acllibgo.FieldPlan)}
+}
+
+// encode accepts the same items as Scrub: a pointer to a struct, a slice, array or map of pointers to struct
+func (s *encodeState) encode(v interface{}) error {
+	if v == nil {
+		return errors.New("acljson: nil item")
+	}
+
+	itemValue := reflect.ValueOf(v)
+	switch itemValue.Kind() {
+	case reflect.Slice, reflect.Array:
+		if itemValue.Type().Elem().Kind() != reflect.Ptr {
+			return errors.New("acljson: expecting pointer for slice or array elements")
+		}
+	case reflect.Map:
+		if itemValue.Type().Elem().Kind() != reflect.Ptr {
+			return errors.New("acljson: expecting pointer for map values")
+		}
+	case reflect.Ptr:
+		if itemValue.IsNil() {
+			return errors.New("acljson: nil " + itemValue.Type().String())
+		}
+		if itemValue.Elem().Kind() != reflect.Struct {
+			return errors.New("acljson: expecting struct, got " + itemValue.Elem().Type().String())
+		}
+	default:
+		return errors.New("acljson: expecting pointer, slice, or map")
+	}
+
+	return s.encodeScrubbed(itemValue)
+}
+
+// encodeScrubbed writes a value in a position Scrub descends into, the other values are written as they are
+func (s *encodeState) encodeScrubbed(v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() && v.Elem().Kind() == reflect.Struct {
+			return s.encodeStruct(v.Elem())
+		}
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Ptr {
+			if v.Kind() == reflect.Slice && v.IsNil() {
+				s.WriteString("null")
+				return nil
+			}
+			s.WriteByte('[')
+			for i := 0; i < v.Len(); i++ {
+				if i > 0 {
+					s.WriteByte(',')
+				}
+				if err := s.encodeScrubbed(v.Index(i)); err != nil {
+					return err
+				}
+			}
+			s.WriteByte(']')
+			return nil
+		}
+	case reflect.Map:
+		if v.Type().Elem().Kind() == reflect.Ptr {
+			return s.encodeMap(v)
+		}
+	}
+
+	return s.encodeValue(v)
+}
+
+func (s *encodeState) encodeMap(v reflect.Value) error {
+	if v.IsNil() {
+		s.WriteString("null")
+		return nil
+	}
+
+	type entry struct {
+		name  string
+		value reflect.Value
+	}
+	entries := make([]entry, 0, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		name, err := keyName(iter.Key())
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry{name: name, value: iter.Value()})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].name < entries[j].name
+	})
+
+	s.WriteByte('{')
+	for x, e := range entries {
+		if x > 0 {
+			s.WriteByte(',')
+		}
+		if err := s.encodeValue(reflect.ValueOf(e.name)); err != nil {
+			return err
+		}
+		s.WriteByte(':')
+		if err := s.encodeScrubbed(e.value); err != nil {
+			return err
+		}
+	}
+	s.WriteByte('}')
+
+	return nil
+}
+
+// encodeStruct writes the addressable struct value with its denied fields zeroed or masked
+func (s *encodeState) encodeStruct(v reflect.Value) error {
+	t := v.Type()
+	plan := jsonPlanOf(t)
+	if !plan.streamable || s.adapted(t, plan) {
+		return s.encodeValue(scrubbedCopy(s.ev, v.Addr()))
+	}
+
+	s.WriteByte('{')
+	first := true
+	for x := range plan.fields {
+		f := &plan.fields[x]
+		fv, ok := s.fieldValue(v, f)
+		if !ok || f.omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+
+		if !first {
+			s.WriteByte(',')
+		}
+		first = false
+		s.Write(f.name)
+
+		var err error
+		switch fv.Kind() {
+		case reflect.Ptr, reflect.Array, reflect.Slice, reflect.Map:
+			if len(f.index) <= f.scrubbed {
+				err = s.encodeScrubbed(fv)
+				break
+			}
+			fallthrough
+		default:
+			err = s.encodeValue(fv)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	s.WriteByte('}')
+
+	return nil
+}
+
+// fieldValue reads the field along its index as Scrub leaves it, reports false when it is not written
+// Promoted fields are not written when an embedded pointer on the way is nil, or omitted by the rules.
+func (s *encodeState) fieldValue(v reflect.Value, f *jsonField) (reflect.Value, bool) {
+	for depth, i := range f.index {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return v, false
+			}
+			v = v.Elem()
+		}
+
+		t := v.Type()
+		fv := v.Field(i)
+		if depth < f.scrubbed && !s.ev.Kept(t, v, i) {
+			if s.fieldsOf(t)[i].Omit {
+				return fv, false
+			}
+			fv = s.ev.Redacted(t, v, i)
+		}
+		v = fv
+	}
+
+	return v, true
+}
+
+// adapted reports whether an adapter walks the struct type or one of the structs Scrub descends into
+// through its embedded pointers, their fields are then not the ones encoding/json writes
+func (s *encodeState) adapted(t reflect.Type, plan *jsonPlan) bool {
+	if s.ev.Adapted(t) {
+		return true
+	}
+	for _, embedded := range plan.embedded {
+		if s.ev.Adapted(embedded) {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeValue writes the value with encoding/json, through its address so pointer receiver marshalers apply
+func (s *encodeState) encodeValue(v reflect.Value) error {
+	var data []byte
+	var err error
+	if v.CanAddr() {
+		data, err = json.Marshal(v.Addr().Interface())
+	} else {
+		data, err = json.Marshal(v.Interface())
+	}
+	if err != nil {
+		return err
+	}
+
+	s.Write(data)
+	return nil
+}
+
+func (s *encodeState) fieldsOf(t reflect.Type) []acllibgo.FieldPlan {
+	fields, ok := s.fields[t]
+	if !ok {
+		fields = s.ev.Fields(t)
+		s.fields[t] = fields
+	}
+	return fields
+}
+
+// scrubbedCopy copies the value along the positions Scrub descends into and scrubs the copy
+// It serves the structs the encoder cannot write field by field, such as ones with a custom marshaler or
+// walked by an adapter.
+func scrubbedCopy(ev *acllibgo.Evaluator, v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() || v.Elem().Kind() != reflect.Struct {
+			return v
+		}
+		t := v.Elem().Type()
+		rv := reflect.New(t)
+		rv.Elem().Set(v.Elem())
+		for _, f := range ev.Fields(t) {
+			if !f.Exported {
+				continue
+			}
+			if !ev.Kept(t, v.Elem(), f.Index) {
+				ev.Redact(t, rv.Elem(), f.Index)
+				continue
+			}
+			switch f.Type.Kind() {
+			case reflect.Ptr, reflect.Array, reflect.Slice, reflect.Map:
+				ev.SetValue(t, rv.Elem(), f.Index, scrubbedCopy(ev, ev.Value(t, v.Elem(), f.Index)))
+			}
+		}
+		return rv
+	case reflect.Slice:
+		if v.IsNil() || v.Type().Elem().Kind() != reflect.Ptr {
+			return v
+		}
+		rv := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			rv.Index(i).Set(scrubbedCopy(ev, v.Index(i)))
+		}
+		return rv
+	case reflect.Array:
+		if v.Type().Elem().Kind() != reflect.Ptr {
+			return v
+		}
+		rv := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			rv.Index(i).Set(scrubbedCopy(ev, v.Index(i)))
+		}
+		return rv
+	case reflect.Map:
+		if v.IsNil() || v.Type().Elem().Kind() != reflect.Ptr {
+			return v
+		}
+		rv := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			rv.SetMapIndex(iter.Key(), scrubbedCopy(ev, iter.Value()))
+		}
+		return rv
+	}
+
+	return v
+}
+
+// jsonPlan lists the fields encoding/json writes for a struct type, in order, promoted fields included
+// A plan is streamable when the fields map one to one to the JSON members, otherwise the struct is
+// encoded from a scrubbed copy.
+type jsonPlan struct {
+	streamable bool
+	fields     []jsonField
+	// embedded lists the struct types Scrub descends into through embedded pointers
+	embedded []reflect.Type
+}
+
+// jsonField is a field written by encoding/json, index is its path from the outer struct as in
+// reflect.Value.FieldByIndex and the first scrubbed levels of the path are the structs Scrub applies
+// the rules of: the outer struct and the ones it reaches through exported embedded pointers.
+type jsonField struct {
+	index     []int
+	scrubbed  int
+	name      []byte
+	omitEmpty bool
+}
+
+var jsonPlans sync.Map
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// jsonPlanOf lists the fields of the struct type the way encoding/json does
+// The fields of untagged embedded structs are promoted breadth first. Among fields with the same name the
+// shallowest wins, then a tagged one; the others at the same depth cancel each other out.
+func jsonPlanOf(t reflect.Type) *jsonPlan {
+	if plan, ok := jsonPlans.Load(t); ok {
+		return plan.(*jsonPlan)
+	}
+
+	type candidate struct {
+		jsonField
+		label  string
+		tagged bool
+	}
+	type level struct {
+		typ      reflect.Type
+		index    []int
+		scrubbed int
+	}
+
+	plan := &jsonPlan{streamable: !implementsMarshaler(t)}
+	var candidates []candidate
+	next := []level{{typ: t, scrubbed: 1}}
+	count, nextCount := map[reflect.Type]int{}, map[reflect.Type]int{}
+	visited := map[reflect.Type]bool{}
+	for len(next) > 0 {
+		current := next
+		next = nil
+		count, nextCount = nextCount, map[reflect.Type]int{}
+
+		for _, l := range current {
+			if visited[l.typ] {
+				continue
+			}
+			visited[l.typ] = true
+
+			for i := 0; i < l.typ.NumField(); i++ {
+				field := l.typ.Field(i)
+				exported := len(field.PkgPath) == 0
+				if field.Anonymous {
+					ft := field.Type
+					if ft.Kind() == reflect.Ptr {
+						ft = ft.Elem()
+					}
+					if !exported && ft.Kind() != reflect.Struct {
+						continue
+					}
+				} else if !exported {
+					continue
+				}
+				tag := field.Tag.Get("json")
+				if tag == "-" {
+					continue
+				}
+
+				parts := strings.Split(tag, ",")
+				name := parts[0]
+				if !isValidName(name) {
+					name = ""
+				}
+				index := append(append(make([]int, 0, len(l.index)+1), l.index...), i)
+
+				ft := field.Type
+				if len(ft.Name()) == 0 && ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+				}
+
+				if len(name) > 0 || !field.Anonymous || ft.Kind() != reflect.Struct {
+					c := candidate{jsonField: jsonField{index: index, scrubbed: l.scrubbed}, label: name, tagged: len(name) > 0}
+					if !c.tagged {
+						c.label = field.Name
+					}
+					for _, opt := range parts[1:] {
+						switch opt {
+						case "omitempty":
+							c.omitEmpty = true
+						case "string":
+							plan.streamable = false
+						}
+					}
+					c.name, _ = json.Marshal(c.label)
+					c.name = append(c.name, ':')
+
+					candidates = append(candidates, c)
+					if count[l.typ] > 1 {
+						// the struct is embedded more than once at this depth, the duplicate cancels the field
+						candidates = append(candidates, c)
+					}
+					continue
+				}
+
+				// Scrub descends into the embedded struct only through an exported pointer
+				scrubbed := l.scrubbed
+				if scrubbed == len(index) && exported && field.Type.Kind() == reflect.Ptr {
+					scrubbed++
+					plan.embedded = append(plan.embedded, ft)
+				}
+				nextCount[ft]++
+				if nextCount[ft] == 1 {
+					next = append(next, level{typ: ft, index: index, scrubbed: scrubbed})
+				}
+			}
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		x, y := candidates[i], candidates[j]
+		if x.label != y.label {
+			return x.label < y.label
+		}
+		if len(x.index) != len(y.index) {
+			return len(x.index) < len(y.index)
+		}
+		if x.tagged != y.tagged {
+			return x.tagged
+		}
+		return lessIndex(x.index, y.index)
+	})
+
+	for i, n := 0, 0; i < len(candidates); i += n {
+		for n = 1; i+n < len(candidates) && candidates[i+n].label == candidates[i].label; n++ {
+		}
+		if n > 1 && len(candidates[i].index) == len(candidates[i+1].index) && candidates[i].tagged == candidates[i+1].tagged {
+			continue
+		}
+		plan.fields = append(plan.fields, candidates[i].jsonField)
+	}
+	sort.Slice(plan.fields, func(i, j int) bool {
+		return lessIndex(plan.fields[i].index, plan.fields[j].index)
+	})
+
+	jsonPlans.Store(t, plan)
+
+	return plan
+}
+
+// lessIndex orders field paths as the fields appear in the struct
+func lessIndex(x, y []int) bool {
+	for k, i := range x {
+		if k >= len(y) {
+			return false
+		}
+		if i != y[k] {
+			return i < y[k]
+		}
+	}
+	return len(x) < len(y)
+}
+
+func implementsMarshaler(t reflect.Type) bool {
+	p := reflect.PtrTo(t)
+	return t.Implements(jsonMarshalerType) || p.Implements(jsonMarshalerType) ||
+		t.Implements(textMarshalerType) || p.Implements(textMarshalerType)
+}
+
+// isValidName reports whether encoding/json accepts the tag name, it falls back to the Go name otherwise
+func isValidName(name string) bool {
+	for _, c := range name {
+		switch {
+		case strings.ContainsRune("!#$%&()*+-./:;<=>?@[]^_{|}~ ", c):
+		case !unicode.IsLetter(c) && !unicode.IsDigit(c):
+			return false
+		}
+	}
+	return true
+}
+
+// keyName renders a map key the way encoding/json does
+func keyName(k reflect.Value) (string, error) {
+	if k.Kind() == reflect.String {
+		return k.String(), nil
+	}
+	if tm, ok := k.Interface().(encoding.TextMarshaler); ok {
+		if k.Kind() == reflect.Ptr && k.IsNil() {
+			return "", nil
+		}
+		text, err := tm.MarshalText()
+		return string(text), err
+	}
+
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	}
+
+	return "", errors.New("acljson: unsupported map key type " + k.Type().String())
+}
+
+// isEmptyValue reports whether encoding/json omits the value of an omitempty field
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
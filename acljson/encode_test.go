@@ -0,0 +1,324 @@
+// Copyright 2020 Alexander Zherdev. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package acljson
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mralexzee/acllibgo"
+	"github.com/stretchr/testify/assert"
+)
+
+type Person struct {
+	Age       int                `json:"age,omitempty"`
+	Height    int32              `json:"height,omitempty" acl:"tester"`
+	Groups    map[string]bool    `json:"groups,omitempty"  acl:"admin"`
+	FullName  []string           `json:"fullName,omitempty" acl:"tester"`
+	Nickname  string             `json:"nickname,omitempty" acl:"*"`
+	Token     string             `acl:"root"`
+	Mother    *Person            `json:"mother,omitempty" acl:"tester"`
+	Father    *Person            `json:"father,omitempty"`
+	Children  []*Person          `json:"children,omitempty"`
+	PetCat    Cat                `json:"petCat,omitempty" acl:"tester"`
+	Friends   map[string]*Person `json:"friends,omitempty"`
+	Ranks     map[int]*Person    `json:"ranks"`
+	Created   time.Time          `json:"created,omitempty"`
+	Birthdate time.Time          `json:"birthdate,omitempty" acl:"tester"`
+	Notes     interface{}        `json:"notes" acl:"admin"`
+	private   string
+}
+
+type Cat struct {
+	Name string `json:"name" acl:"root,account"`
+	Type string `json:"type" acl:"root"`
+}
+
+// Badge has a custom marshaler so it is encoded from a scrubbed copy
+type Badge struct {
+	Label  string `acl:"admin"`
+	Number int
+}
+
+func (b Badge) MarshalJSON() ([]byte, error) {
+	return json.Marshal(b.Label + "#" + string(rune('0'+b.Number)))
+}
+
+// Employee quotes a field so it is encoded from a scrubbed copy
+type Employee struct {
+	*Person
+	Salary int    `json:"salary,string" acl:"admin"`
+	Badge  *Badge `json:"badge"`
+}
+
+func newPerson() *Person {
+	created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	return &Person{
+		Age: 40, Height: 180, Groups: map[string]bool{"a": true}, FullName: []string{"John", "Doe"},
+		Nickname: "JD", Token: "<secret>", Created: created, Birthdate: created, Notes: map[string]int{"x": 1},
+		PetCat:  Cat{Name: "Tom", Type: "cat"},
+		Mother:  &Person{Age: 70, Height: 160, Nickname: "Mom", Token: "m"},
+		Father:  &Person{Age: 72, Token: "f", Children: []*Person{nil, {Age: 1, Token: "c"}}},
+		Friends: map[string]*Person{"b": {Age: 30, Token: "b"}, "a": {Age: 31, Token: "a"}, "n": nil},
+		Ranks:   map[int]*Person{10: {Age: 10, Nickname: "ten"}, 2: {Age: 2}},
+		private: "private",
+	}
+}
+
+func newEmployee() *Employee {
+	return &Employee{Person: newPerson(), Salary: 1000, Badge: &Badge{Label: "staff", Number: 7}}
+}
+
+func scrubbedJSON(t *testing.T, v interface{}, groups []string) string {
+	assert.NoError(t, acllibgo.Scrub(v, groups))
+	data, err := json.Marshal(v)
+	assert.NoError(t, err)
+	return string(data)
+}
+
+var groupSets = [][]string{{}, {"tester"}, {"admin"}, {"root", "admin"}, {"account", "tester"}}
+
+func Test_Marshal_SameAsScrub(t *testing.T) {
+
+	for _, groups := range groupSets {
+		item := newPerson()
+		data, err := Marshal(item, groups)
+		assert.NoError(t, err)
+		assert.Equal(t, scrubbedJSON(t, newPerson(), groups), string(data), strings.Join(groups, ","))
+		assert.Equal(t, newPerson(), item)
+	}
+}
+
+func Test_Marshal_Collections(t *testing.T) {
+
+	for _, groups := range groupSets {
+		list := []*Person{newPerson(), nil, newPerson()}
+		data, err := Marshal(list, groups)
+		assert.NoError(t, err)
+		assert.Equal(t, scrubbedJSON(t, []*Person{newPerson(), nil, newPerson()}, groups), string(data))
+
+		index := map[string]*Person{"z": newPerson(), "y": newPerson()}
+		data, err = Marshal(index, groups)
+		assert.NoError(t, err)
+		assert.Equal(t, scrubbedJSON(t, map[string]*Person{"z": newPerson(), "y": newPerson()}, groups), string(data))
+	}
+}
+
+func Test_Marshal_Fallback(t *testing.T) {
+
+	for _, groups := range groupSets {
+		item := newEmployee()
+		data, err := Marshal(item, groups)
+		assert.NoError(t, err)
+		assert.Equal(t, scrubbedJSON(t, newEmployee(), groups), string(data))
+		assert.Equal(t, newEmployee(), item)
+	}
+}
+
+func Test_Marshal_Mask(t *testing.T) {
+
+	e := acllibgo.NewEngine(acllibgo.Options{})
+	e.RegisterTypes(reflect.TypeOf(Person{}))
+	policy, err := acllibgo.ParsePolicy([]byte(`{"types":{"github.com/mralexzee/acllibgo/acljson.Person":{"fields":{"Token":{"mask":"***"}}}}}`))
+	assert.NoError(t, err)
+	assert.NoError(t, e.SetPolicy(policy))
+
+	buf := new(bytes.Buffer)
+	assert.NoError(t, NewEncoder(buf).WithEngine(e).WithGroups("tester").Encode(newPerson()))
+
+	expected := newPerson()
+	assert.NoError(t, e.Scrub(expected, []string{"tester"}))
+	data, err := json.Marshal(expected)
+	assert.NoError(t, err)
+	assert.Equal(t, string(data)+"\n", buf.String())
+	assert.Contains(t, buf.String(), `"Token":"***"`)
+}
+
+func Test_Encoder_Subject(t *testing.T) {
+
+	buf := new(bytes.Buffer)
+	assert.NoError(t, NewEncoder(buf).Encode(&Cat{Name: "Tom", Type: "cat"}))
+	assert.Equal(t, `{"name":"","type":""}`+"\n", buf.String())
+
+	buf.Reset()
+	assert.NoError(t, NewEncoder(buf).WithSubject(acllibgo.Subject{Groups: []string{"account"}}).Encode(&Cat{Name: "Tom", Type: "cat"}))
+	assert.Equal(t, `{"name":"Tom","type":""}`+"\n", buf.String())
+}
+
+func Test_Marshal_Errors(t *testing.T) {
+
+	_, err := Marshal(newPerson(), nil)
+	assert.EqualError(t, err, "acljson: nil acl")
+
+	_, err = Marshal(nil, []string{})
+	assert.EqualError(t, err, "acljson: nil item")
+
+	var person *Person
+	_, err = Marshal(person, []string{})
+	assert.EqualError(t, err, "acljson: nil *acljson.Person")
+
+	_, err = Marshal(Person{}, []string{})
+	assert.EqualError(t, err, "acljson: expecting pointer, slice, or map")
+
+	_, err = Marshal([]Person{}, []string{})
+	assert.EqualError(t, err, "acljson: expecting pointer for slice or array elements")
+}
+
+func Benchmark_Marshal(b *testing.B) {
+	item := newPerson()
+	groups := []string{"tester"}
+	for n := 0; n < b.N; n++ {
+		Marshal(item, groups)
+	}
+}
+
+type Account struct {
+	Login    string
+	Password string `acl:"root" aclmode:"omit"`
+	Email    string `json:"email" acl:"admin" aclmode:"omit"`
+	Phone    string `json:"phone" acl:"admin"`
+}
+
+func Test_Marshal_Omit(t *testing.T) {
+
+	item := &Account{Login: "john", Password: "secret", Email: "john@example.com", Phone: "555"}
+
+	data, err := Marshal(item, []string{"user"})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"Login":"john","phone":""}`, string(data))
+
+	data, err = Marshal(item, []string{"admin"})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"Login":"john","email":"john@example.com","phone":"555"}`, string(data))
+	assert.Equal(t, "secret", item.Password)
+}
+
+func Test_Marshal_OmitPolicy(t *testing.T) {
+
+	e := acllibgo.NewEngine(acllibgo.Options{})
+	e.RegisterTypes(reflect.TypeOf(Account{}))
+	policy, err := acllibgo.ParsePolicy([]byte(`{"types":{"github.com/mralexzee/acllibgo/acljson.Account":{"fields":{"Phone":{"mode":"omit"},"Email":{"mode":"zero"}}}}}`))
+	assert.NoError(t, err)
+	assert.NoError(t, e.SetPolicy(policy))
+
+	buf := new(bytes.Buffer)
+	assert.NoError(t, NewEncoder(buf).WithEngine(e).WithGroups("user").Encode(&Account{Login: "john", Phone: "555"}))
+	assert.Equal(t, `{"Login":"john","email":""}`+"\n", buf.String())
+}
+
+// Model is embedded the way ORM base models are
+type Model struct {
+	ID      uint
+	Created time.Time `json:"-"`
+}
+
+// Customer promotes the fields of a struct value and of a pointer, the rules of Account apply to its own
+type Customer struct {
+	Model
+	*Account
+	Name  string
+	Token string `acl:"admin" aclmode:"omit"`
+}
+
+// Ref conflicts with Model on ID, its tagged ID wins, and with Linked on Kind, the shallower wins
+type Ref struct {
+	ID   string `json:"ID"`
+	Kind string
+}
+
+type Linked struct {
+	Model
+	Ref
+	Kind string `acl:"admin"`
+}
+
+func Test_Marshal_Embedded(t *testing.T) {
+
+	newCustomer := func() *Customer {
+		return &Customer{Model: Model{ID: 1}, Account: &Account{Login: "john", Password: "secret", Email: "e", Phone: "555"}, Name: "n", Token: "t"}
+	}
+
+	data, err := Marshal(newCustomer(), []string{"user"})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"ID":1,"Login":"john","phone":"","Name":"n"}`, string(data))
+
+	data, err = Marshal(&Customer{Model: Model{ID: 1}, Name: "n", Token: "t"}, []string{"user"})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"ID":1,"Name":"n"}`, string(data))
+
+	// nothing omitted, same as Scrub
+	data, err = Marshal(newCustomer(), []string{"root", "admin"})
+	assert.NoError(t, err)
+	assert.Equal(t, scrubbedJSON(t, newCustomer(), []string{"root", "admin"}), string(data))
+
+	for _, groups := range groupSets {
+		item := &Linked{Model: Model{ID: 1}, Ref: Ref{ID: "r1", Kind: "ref"}, Kind: "linked"}
+		data, err = Marshal(item, groups)
+		assert.NoError(t, err)
+		assert.Equal(t, scrubbedJSON(t, &Linked{Model: Model{ID: 1}, Ref: Ref{ID: "r1", Kind: "ref"}, Kind: "linked"}, groups), string(data))
+	}
+}
+
+// Message models a generated message, its internal fields come first
+type Message struct {
+	sizeCache int32
+	unknown   []byte
+
+	Id     string   `json:"id"`
+	Body   string   `json:"body"`
+	Sender *Message `json:"sender,omitempty"`
+}
+
+// messageAdapter takes the rules of the fields from a map instead of tags
+type messageAdapter struct {
+	acllibgo.StructAdapter
+	acls map[string]string
+}
+
+func (a messageAdapter) Accepts(t reflect.Type) bool {
+	return t == reflect.TypeOf(Message{})
+}
+
+func (a messageAdapter) Fields(t reflect.Type) []acllibgo.AdapterField {
+	rv := a.StructAdapter.Fields(t)
+	for x := range rv {
+		rv[x].Acl = a.acls[rv[x].Name]
+		if rv[x].Name == "Body" {
+			rv[x].Mask = "***"
+		}
+	}
+	return rv
+}
+
+func newMessage() *Message {
+	return &Message{sizeCache: 7, unknown: []byte{1}, Id: "m1", Body: "hello", Sender: &Message{sizeCache: 3, Id: "m0", Body: "hi"}}
+}
+
+func Test_Marshal_Adapter(t *testing.T) {
+
+	e := acllibgo.NewEngine(acllibgo.Options{})
+	e.RegisterAdapter(messageAdapter{acls: map[string]string{"Id": "public", "Body": "staff", "Sender": "staff,audit"}})
+
+	for _, groups := range [][]string{{}, {"staff"}, {"audit"}} {
+		item := newMessage()
+		buf := new(bytes.Buffer)
+		assert.NoError(t, NewEncoder(buf).WithEngine(e).WithGroups(groups...).Encode(item))
+
+		expected := newMessage()
+		assert.NoError(t, e.Scrub(expected, groups))
+		data, err := json.Marshal(expected)
+		assert.NoError(t, err)
+		assert.Equal(t, string(data)+"\n", buf.String())
+		assert.Equal(t, newMessage(), item)
+	}
+
+	buf := new(bytes.Buffer)
+	assert.NoError(t, NewEncoder(buf).WithEngine(e).WithGroups("audit").Encode(newMessage()))
+	assert.Equal(t, `{"id":"m1","body":"***","sender":{"id":"m0","body":"***"}}`+"\n", buf.String())
+}
@@ -0,0 +1,105 @@
+// Copyright 2020 Alexander Zherdev. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package acllog
+
+import (
+	"context"
+	"log/slog"
+	"reflect"
+
+	"github.com/mralexzee/acllibgo"
+)
+
+// Handler wraps a slog.Handler and applies 'acl' rules to the attributes holding structs
+// Attribute values of kind Any whose type may hold structs, pointers, slices and maps of structs
+// included, are converted as by Value before the wrapped handler sees them. Other values pass through,
+// as do errors, fmt.Stringers and marshalers the rules leave intact.
+type Handler struct {
+	next    slog.Handler
+	engine  *acllibgo.Engine
+	subject acllibgo.Subject
+}
+
+// NewHandler returns a handler logging structs to next for the groups
+func NewHandler(next slog.Handler, groups []string) *Handler {
+	return &Handler{next: next, subject: acllibgo.Subject{Groups: groups}}
+}
+
+// WithEngine returns a handler evaluating the rules with the engine instead of the default one
+func (h *Handler) WithEngine(engine *acllibgo.Engine) *Handler {
+	rv := *h
+	rv.engine = engine
+	return &rv
+}
+
+// WithSubject returns a handler logging for the subject instead of the groups
+func (h *Handler) WithSubject(subject acllibgo.Subject) *Handler {
+	rv := *h
+	rv.subject = subject
+	return &rv
+}
+
+// Enabled reports whether the wrapped handler handles records at the level
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+// Handle redacts the attributes of the record and passes it to the wrapped handler
+func (h *Handler) Handle(ctx context.Context, record slog.Record) error {
+	var c *converter
+	redacted := slog.NewRecord(record.Time, record.Level, record.Message, record.PC)
+	record.Attrs(func(attr slog.Attr) bool {
+		redacted.AddAttrs(h.redact(&c, attr))
+		return true
+	})
+
+	return h.next.Handle(ctx, redacted)
+}
+
+// WithAttrs redacts the attributes and passes them to the wrapped handler
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	var c *converter
+	redacted := make([]slog.Attr, len(attrs))
+	for x, attr := range attrs {
+		redacted[x] = h.redact(&c, attr)
+	}
+
+	rv := *h
+	rv.next = h.next.WithAttrs(redacted)
+	return &rv
+}
+
+// WithGroup opens a group on the wrapped handler
+func (h *Handler) WithGroup(name string) slog.Handler {
+	rv := *h
+	rv.next = h.next.WithGroup(name)
+	return &rv
+}
+
+// redact converts the attribute value when it may hold structs, the converter is created on first use
+func (h *Handler) redact(c **converter, attr slog.Attr) slog.Attr {
+	attr.Value = attr.Value.Resolve()
+
+	switch attr.Value.Kind() {
+	case slog.KindGroup:
+		group := attr.Value.Group()
+		attrs := make([]slog.Attr, len(group))
+		for x, a := range group {
+			attrs[x] = h.redact(c, a)
+		}
+		attr.Value = slog.GroupValue(attrs...)
+	case slog.KindAny:
+		v := reflect.ValueOf(attr.Value.Any())
+		if !v.IsValid() || !hasStructs(v.Type()) {
+			return attr
+		}
+		if *c == nil {
+			*c = newConverter(h.engine, h.subject)
+		}
+		attr.Value = (*c).value(v).Resolve()
+	}
+
+	return attr
+}
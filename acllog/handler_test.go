@@ -0,0 +1,95 @@
+// Copyright 2020 Alexander Zherdev. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package acllog
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"testing"
+
+	"github.com/mralexzee/acllibgo"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Handler(t *testing.T) {
+
+	wrap := func(next slog.Handler) slog.Handler {
+		return NewHandler(next, []string{"admin"})
+	}
+	user := newUser()
+	record := logJSON(t, wrap, "user", user, "count", 3, slog.Group("request", "address", user.Address, "path", "/login"))
+
+	assert.Equal(t, 3.0, record["count"])
+	assert.Equal(t, map[string]interface{}{"address": map[string]interface{}{"city": "Springfield", "street": "Main St"}, "path": "/login"}, record["request"])
+
+	logged := record["user"].(map[string]interface{})
+	assert.Equal(t, "john@example.com", logged["email"])
+	assert.NotContains(t, logged, "token")
+	assert.Equal(t, map[string]interface{}{"number": "", "brand": ""}, logged["card"])
+	assert.Equal(t, newUser(), user)
+}
+
+func Test_Handler_WithAttrs(t *testing.T) {
+
+	wrap := func(next slog.Handler) slog.Handler {
+		return NewHandler(next, []string{}).WithSubject(acllibgo.Subject{Groups: []string{"support"}}).WithAttrs([]slog.Attr{slog.Any("address", newUser().Address)}).WithGroup("g")
+	}
+	record := logJSON(t, wrap, "users", []*User{newUser()})
+
+	assert.Equal(t, map[string]interface{}{"city": "Springfield", "street": ""}, record["address"])
+	users := record["g"].(map[string]interface{})["users"].(map[string]interface{})
+	assert.Equal(t, "john@example.com", users["0"].(map[string]interface{})["email"])
+}
+
+func Test_Handler_WithEngine(t *testing.T) {
+
+	e := acllibgo.NewEngine(acllibgo.Options{DenyUntagged: true})
+	wrap := func(next slog.Handler) slog.Handler {
+		return NewHandler(next, []string{}).WithEngine(e)
+	}
+	record := logJSON(t, wrap, "address", newUser().Address)
+	assert.Equal(t, map[string]interface{}{"city": "", "street": ""}, record["address"])
+}
+
+// LoginError is an error whose fields carry rules
+type LoginError struct {
+	User     string `json:"user"`
+	Password string `json:"password" acl:"root"`
+}
+
+func (e *LoginError) Error() string {
+	return "login failed for " + e.User + " with " + e.Password
+}
+
+func Test_Handler_Errors(t *testing.T) {
+
+	wrap := func(next slog.Handler) slog.Handler {
+		return NewHandler(next, []string{"support"})
+	}
+	err := errors.New("boom")
+	record := logJSON(t, wrap, "err", err, "wrapped", fmt.Errorf("w: %w", err),
+		"path", &fs.PathError{Op: "open", Path: "/etc/app.conf", Err: fs.ErrNotExist})
+
+	// errors are logged by their message
+	assert.Equal(t, "boom", record["err"])
+	assert.Equal(t, "w: boom", record["wrapped"])
+	assert.Equal(t, "open /etc/app.conf: file does not exist", record["path"])
+
+	// an error with a denied field is converted, its message would reveal the field
+	record = logJSON(t, wrap, "err", &LoginError{User: "john", Password: "secret"})
+	assert.Equal(t, map[string]interface{}{"user": "john", "password": ""}, record["err"])
+}
+
+func Benchmark_Handler(b *testing.B) {
+	logger := slog.New(NewHandler(slog.NewJSONHandler(io.Discard, nil), []string{"support"}))
+	user := newUser()
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		logger.Info("login", "user", user)
+	}
+}
@@ -0,0 +1,253 @@
+// Copyright 2020 Alexander Zherdev. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package acllog logs structs through log/slog with their 'acl' rules applied
+//
+//	logger.Info("login", "user", acllog.Value(user, []string{"support"}))
+//	logger := slog.New(acllog.NewHandler(slog.NewJSONHandler(os.Stdout, nil), []string{"support"}))
+//
+// Structs are converted to slog groups keyed by their JSON names, nested structs included, using the
+// per-type plans of the engine. The logged value is never altered: a denied field is logged with its
+// mask or its zero value, or left out when tagged aclmode:"omit". Errors, fmt.Stringers and marshalers
+// are logged as they are, formatted by the handler, unless the rules deny one of their fields.
+package acllog
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/mralexzee/acllibgo"
+)
+
+// cycleValue is logged in place of a pointer already being logged
+const cycleValue = "<cycle>"
+
+// Value returns a slog.LogValuer logging v for the groups with the default engine
+func Value(v interface{}, groups []string) slog.LogValuer {
+	return ValueFor(nil, v, groups)
+}
+
+// ValueFor returns a slog.LogValuer logging v for the groups with the engine, the default one when nil
+func ValueFor(engine *acllibgo.Engine, v interface{}, groups []string) slog.LogValuer {
+	return value{engine: engine, v: v, subject: acllibgo.Subject{Groups: groups}}
+}
+
+type value struct {
+	engine  *acllibgo.Engine
+	v       interface{}
+	subject acllibgo.Subject
+}
+
+// LogValue converts the value when the record is handled
+func (v value) LogValue() slog.Value {
+	return newConverter(v.engine, v.subject).value(reflect.ValueOf(v.v))
+}
+
+// converter turns values into slog values with the rules evaluated for a subject
+type converter struct {
+	ev       *acllibgo.Evaluator
+	fields   map[reflect.Type][]acllibgo.FieldPlan
+	visiting map[uintptr]bool
+}
+
+func newConverter(engine *acllibgo.Engine, subject acllibgo.Subject) *converter {
+	var ev *acllibgo.Evaluator
+	if engine != nil {
+		ev = engine.NewEvaluator(subject)
+	} else {
+		ev = acllibgo.NewEvaluator(subject)
+	}
+	return &converter{ev: ev, fields: make(map[reflect.Type][]acllibgo.FieldPlan), visiting: make(map[uintptr]bool)}
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+	valuerType   = reflect.TypeOf((*slog.LogValuer)(nil)).Elem()
+
+	// formatterTypes are the interfaces handlers format values with, such values are logged as they are
+	// when the rules leave them intact
+	formatterTypes = []reflect.Type{
+		reflect.TypeOf((*error)(nil)).Elem(),
+		reflect.TypeOf((*fmt.Stringer)(nil)).Elem(),
+		reflect.TypeOf((*json.Marshaler)(nil)).Elem(),
+		reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem(),
+	}
+)
+
+func (c *converter) value(v reflect.Value) slog.Value {
+	if !v.IsValid() {
+		return slog.AnyValue(nil)
+	}
+
+	switch v.Type() {
+	case timeType:
+		return slog.TimeValue(v.Interface().(time.Time))
+	case durationType:
+		return slog.DurationValue(time.Duration(v.Int()))
+	}
+	if v.Type().Implements(valuerType) && v.CanInterface() {
+		return slog.AnyValue(v.Interface())
+	}
+	if isFormatter(v.Type()) && v.CanInterface() && c.intact(v) {
+		return slog.AnyValue(v.Interface())
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return slog.AnyValue(nil)
+		}
+		if v.Kind() == reflect.Ptr {
+			addr := v.Pointer()
+			if c.visiting[addr] {
+				return slog.StringValue(cycleValue)
+			}
+			c.visiting[addr] = true
+			defer delete(c.visiting, addr)
+		}
+		return c.value(v.Elem())
+	case reflect.Struct:
+		return slog.GroupValue(c.structAttrs(v)...)
+	case reflect.Slice, reflect.Array:
+		if !hasStructs(v.Type().Elem()) {
+			break
+		}
+		attrs := make([]slog.Attr, v.Len())
+		for i := range attrs {
+			attrs[i] = slog.Attr{Key: strconv.Itoa(i), Value: c.value(v.Index(i))}
+		}
+		return slog.GroupValue(attrs...)
+	case reflect.Map:
+		if !hasStructs(v.Type().Elem()) {
+			break
+		}
+		attrs := make([]slog.Attr, 0, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			attrs = append(attrs, slog.Attr{Key: keyString(iter.Key()), Value: c.value(iter.Value())})
+		}
+		return slog.GroupValue(attrs...)
+	}
+
+	if !v.CanInterface() {
+		return slog.AnyValue(nil)
+	}
+	return slog.AnyValue(v.Interface())
+}
+
+// structAttrs lists the exported fields of the struct value, denied ones redacted
+func (c *converter) structAttrs(v reflect.Value) []slog.Attr {
+	t := v.Type()
+	fields := c.fieldsOf(t)
+
+	attrs := make([]slog.Attr, 0, len(fields))
+	for _, f := range fields {
+		if !f.Exported || f.JsonName == "-" {
+			continue
+		}
+
+		fv := c.ev.Value(t, v, f.Index)
+		if !c.ev.Kept(t, v, f.Index) {
+			if f.Omit {
+				continue
+			}
+			fv = c.ev.Redacted(t, v, f.Index)
+		}
+
+		attrs = append(attrs, slog.Attr{Key: f.JsonName, Value: c.value(fv)})
+	}
+
+	return attrs
+}
+
+func (c *converter) fieldsOf(t reflect.Type) []acllibgo.FieldPlan {
+	fields, ok := c.fields[t]
+	if !ok {
+		fields = c.ev.Fields(t)
+		c.fields[t] = fields
+	}
+	return fields
+}
+
+// intact reports whether the rules keep every field of the value, so it may be formatted by its own methods
+func (c *converter) intact(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return true
+		}
+		if v.Kind() == reflect.Ptr {
+			addr := v.Pointer()
+			if c.visiting[addr] {
+				return true
+			}
+			c.visiting[addr] = true
+			defer delete(c.visiting, addr)
+		}
+		return c.intact(v.Elem())
+	case reflect.Struct:
+		t := v.Type()
+		for _, f := range c.fieldsOf(t) {
+			if f.Exported && (!c.ev.Kept(t, v, f.Index) || !c.intact(c.ev.Value(t, v, f.Index))) {
+				return false
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if hasStructs(v.Type().Elem()) {
+			for i := 0; i < v.Len(); i++ {
+				if !c.intact(v.Index(i)) {
+					return false
+				}
+			}
+		}
+	case reflect.Map:
+		if hasStructs(v.Type().Elem()) {
+			iter := v.MapRange()
+			for iter.Next() {
+				if !c.intact(iter.Value()) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+func isFormatter(t reflect.Type) bool {
+	for _, f := range formatterTypes {
+		if t.Implements(f) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasStructs reports whether values of the type may hold structs, which carry rules
+func hasStructs(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		return t != timeType
+	case reflect.Interface:
+		return true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return hasStructs(t.Elem())
+	}
+	return false
+}
+
+func keyString(k reflect.Value) string {
+	if k.Kind() == reflect.String {
+		return k.String()
+	}
+	return slog.AnyValue(k.Interface()).String()
+}
@@ -0,0 +1,180 @@
+// Copyright 2020 Alexander Zherdev. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package acllog
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mralexzee/acllibgo"
+	"github.com/stretchr/testify/assert"
+)
+
+type User struct {
+	ID       int               `json:"id"`
+	Name     string            `json:"name"`
+	Email    string            `json:"email" acl:"support,admin"`
+	Password string            `json:"-"`
+	Token    string            `json:"token" acl:"root" aclmode:"omit"`
+	Card     Card              `json:"card" acl:"billing"`
+	Address  *Address          `json:"address"`
+	Friends  []*User           `json:"friends,omitempty"`
+	Labels   map[string]string `json:"labels"`
+	Created  time.Time         `json:"created"`
+	Manager  *User             `json:"manager,omitempty"`
+}
+
+type Card struct {
+	Number string `json:"number" acl:"billing"`
+	Brand  string `json:"brand"`
+}
+
+type Address struct {
+	City   string `json:"city"`
+	Street string `json:"street" acl:"admin"`
+}
+
+var created = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func newUser() *User {
+	return &User{
+		ID: 1, Name: "John", Email: "john@example.com", Password: "pass", Token: "tok",
+		Card:    Card{Number: "4111", Brand: "visa"},
+		Address: &Address{City: "Springfield", Street: "Main St"},
+		Friends: []*User{{ID: 2, Name: "Jane", Email: "jane@example.com"}},
+		Labels:  map[string]string{"tier": "gold"},
+		Created: created,
+	}
+}
+
+// logJSON logs the attribute with a JSON handler and returns the decoded record
+func logJSON(t *testing.T, handler func(slog.Handler) slog.Handler, args ...interface{}) map[string]interface{} {
+	buf := new(bytes.Buffer)
+	var h slog.Handler = slog.NewJSONHandler(buf, nil)
+	if handler != nil {
+		h = handler(h)
+	}
+	slog.New(h).Info("msg", args...)
+
+	var rv map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &rv))
+	return rv
+}
+
+func Test_Value(t *testing.T) {
+
+	user := newUser()
+	record := logJSON(t, nil, "user", Value(user, []string{"support"}))
+
+	assert.Equal(t, map[string]interface{}{
+		"id":      1.0,
+		"name":    "John",
+		"email":   "john@example.com",
+		"card":    map[string]interface{}{"number": "", "brand": ""},
+		"address": map[string]interface{}{"city": "Springfield", "street": ""},
+		"friends": map[string]interface{}{
+			"0": map[string]interface{}{
+				"id": 2.0, "name": "Jane", "email": "jane@example.com", "card": map[string]interface{}{"number": "", "brand": ""},
+				"address": nil, "labels": nil, "created": "0001-01-01T00:00:00Z", "manager": nil,
+			},
+		},
+		"labels":  map[string]interface{}{"tier": "gold"},
+		"created": "2020-01-02T03:04:05Z",
+		"manager": nil,
+	}, record["user"])
+	assert.Equal(t, newUser(), user)
+}
+
+func Test_Value_Groups(t *testing.T) {
+
+	record := logJSON(t, nil, "user", Value(newUser(), []string{"root", "billing"}))
+	user := record["user"].(map[string]interface{})
+	assert.Equal(t, "tok", user["token"])
+	assert.Equal(t, "", user["email"])
+	assert.Equal(t, map[string]interface{}{"number": "4111", "brand": "visa"}, user["card"])
+}
+
+func Test_Value_Cycle(t *testing.T) {
+
+	user := newUser()
+	user.Manager = user
+	record := logJSON(t, nil, "user", Value(user, []string{}))
+	assert.Equal(t, "<cycle>", record["user"].(map[string]interface{})["manager"])
+}
+
+func Test_ValueFor(t *testing.T) {
+
+	e := acllibgo.NewEngine(acllibgo.Options{DenyUntagged: true})
+	record := logJSON(t, nil, "address", ValueFor(e, &Address{City: "Springfield", Street: "Main St"}, []string{"admin"}))
+	assert.Equal(t, map[string]interface{}{"city": "", "street": "Main St"}, record["address"])
+}
+
+func Benchmark_Value(b *testing.B) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	user := newUser()
+	groups := []string{"support"}
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		logger.Info("login", "user", Value(user, groups))
+	}
+}
+
+func Benchmark_Value_Unredacted(b *testing.B) {
+	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
+	user := newUser()
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		logger.Info("login", "user", user)
+	}
+}
+
+// Session models a generated message whose internal state precedes the fields
+type Session struct {
+	state  int
+	cached []byte
+
+	ID    string `json:"id"`
+	Token string `json:"token"`
+	User  *User  `json:"user"`
+}
+
+// sessionAdapter reads the rules of the Session fields from a map keyed by JSON name
+type sessionAdapter struct {
+	acllibgo.StructAdapter
+	acls map[string]string
+}
+
+func (a sessionAdapter) Accepts(t reflect.Type) bool {
+	return t == reflect.TypeOf(Session{})
+}
+
+func (a sessionAdapter) Fields(t reflect.Type) []acllibgo.AdapterField {
+	rv := a.StructAdapter.Fields(t)
+	for x := range rv {
+		rv[x].Acl = a.acls[rv[x].JsonName]
+	}
+	return rv
+}
+
+func Test_Value_Adapter(t *testing.T) {
+
+	e := acllibgo.NewEngine(acllibgo.Options{})
+	e.RegisterAdapter(sessionAdapter{acls: map[string]string{"id": "public", "token": "root"}})
+
+	session := &Session{state: 2, cached: []byte{1}, ID: "s1", Token: "tok", User: &User{ID: 1, Name: "John", Email: "john@example.com"}}
+	record := logJSON(t, nil, "session", ValueFor(e, session, []string{"support"}))
+
+	logged := record["session"].(map[string]interface{})
+	assert.Equal(t, "s1", logged["id"])
+	assert.Equal(t, "", logged["token"])
+	assert.Equal(t, "john@example.com", logged["user"].(map[string]interface{})["email"])
+	assert.Len(t, logged, 3)
+	assert.Equal(t, "tok", session.Token)
+}
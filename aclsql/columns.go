@@ -0,0 +1,222 @@
+// Copyright 2020 Alexander Zherdev. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package aclsql applies 'acl' rules to structs read from and written to a database/sql database
+//
+//	columns, values, err := aclsql.Columns(&user, groups)
+//	// INSERT INTO users (columns...) VALUES (values...)
+//
+//	for rows.Next() {
+//		err = aclsql.Scan(rows, &user, groups)
+//	}
+//
+// Columns are named by the 'db' tag of the fields, the gorm column:name tag setting, or the lower-cased
+// field name, and a db:"-" or gorm:"-" field is not a column. The fields of embedded structs are columns
+// of the outer struct. Relations, fields holding pointers, slices, or maps of structs, are not columns,
+// Select lists them as joins. Types walked by an acllibgo.Adapter have no such tags and are rejected.
+package aclsql
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+
+	"github.com/mralexzee/acllibgo"
+)
+
+const (
+	dbTagName   = "db"
+	gormTagName = "gorm"
+)
+
+// Mapper maps structs to columns for a subject
+type Mapper struct {
+	engine  *acllibgo.Engine
+	subject acllibgo.Subject
+}
+
+// NewMapper returns a mapper for the groups
+func NewMapper(groups []string) *Mapper {
+	return &Mapper{subject: acllibgo.Subject{Groups: groups}}
+}
+
+// WithEngine returns a mapper evaluating the rules with the engine instead of the default one
+func (m *Mapper) WithEngine(engine *acllibgo.Engine) *Mapper {
+	rv := *m
+	rv.engine = engine
+	return &rv
+}
+
+// WithSubject returns a mapper for the subject instead of the groups
+func (m *Mapper) WithSubject(subject acllibgo.Subject) *Mapper {
+	rv := *m
+	rv.subject = subject
+	return &rv
+}
+
+// Columns returns the columns and values of the struct the groups may write, for INSERT and UPDATE
+// statements. Denied fields are left out rather than written as zero values, so an UPDATE keeps the
+// stored value and an INSERT the column default.
+func Columns(item interface{}, groups []string) ([]string, []interface{}, error) {
+	if groups == nil {
+		return nil, nil, errors.New("aclsql: nil acl")
+	}
+	return NewMapper(groups).Columns(item)
+}
+
+// Columns returns the columns and values of the struct the subject may write, see Columns
+func (m *Mapper) Columns(item interface{}) ([]string, []interface{}, error) {
+	v, err := structValue(item)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	columns, err := m.columns(m.evaluator(), v, false, true, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	names := []string{}
+	values := []interface{}{}
+	for _, c := range columns {
+		if c.kept && !c.embedded {
+			names = append(names, c.name)
+			values = append(values, c.value.Interface())
+		}
+	}
+
+	return names, values, nil
+}
+
+func (m *Mapper) evaluator() *acllibgo.Evaluator {
+	if m.engine != nil {
+		return m.engine.NewEvaluator(m.subject)
+	}
+	return acllibgo.NewEvaluator(m.subject)
+}
+
+// column is a field mapped to a database column
+// An embedded struct pointer is listed as an embedded column before its own columns, it is not a database column.
+type column struct {
+	name     string
+	value    reflect.Value
+	plan     acllibgo.FieldPlan
+	kept     bool
+	embedded bool
+}
+
+// columns lists the columns of the struct value v, the fields of embedded structs included
+// A nil embedded pointer is allocated when alloc is set, its columns are skipped otherwise. Types walked
+// by an acllibgo.Adapter are rejected, their fields carry no column tags and may not be addressable.
+func (m *Mapper) columns(ev *acllibgo.Evaluator, v reflect.Value, alloc bool, kept bool, rv []column) ([]column, error) {
+	t := v.Type()
+	if ev.Adapted(t) {
+		return nil, adaptedError(t)
+	}
+
+	for _, f := range ev.Fields(t) {
+		if !f.Exported {
+			continue
+		}
+
+		name, tagged := columnName(t.Field(f.Index))
+		if name == "-" {
+			continue
+		}
+
+		fieldKept := kept && ev.Kept(t, v, f.Index)
+		fv := v.Field(f.Index)
+		if f.Anonymous && !tagged {
+			embedded := fv
+			if embedded.Kind() == reflect.Ptr && embedded.Type().Elem().Kind() == reflect.Struct {
+				if embedded.IsNil() {
+					if !alloc {
+						continue
+					}
+					if ev.Adapted(embedded.Type().Elem()) {
+						return nil, adaptedError(embedded.Type().Elem())
+					}
+					embedded.Set(reflect.New(embedded.Type().Elem()))
+				}
+				rv = append(rv, column{name: name, value: fv, plan: f, kept: fieldKept, embedded: true})
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				var err error
+				if rv, err = m.columns(ev, embedded, alloc, fieldKept, rv); err != nil {
+					return nil, err
+				}
+				continue
+			}
+		}
+
+		// relations are loaded through joins, see Select
+		if _, _, ok := relation(f.Type); ok {
+			continue
+		}
+
+		rv = append(rv, column{name: name, value: fv, plan: f, kept: fieldKept})
+	}
+
+	return rv, nil
+}
+
+func adaptedError(t reflect.Type) error {
+	return errors.New("aclsql: adapted type " + t.String() + " is not supported")
+}
+
+// columnName returns the column of the field and whether it is named by a tag
+func columnName(f reflect.StructField) (string, bool) {
+	tag := f.Tag.Get(dbTagName)
+	if x := strings.Index(tag, ","); x >= 0 {
+		tag = tag[:x]
+	}
+	tag = strings.TrimSpace(tag)
+	if len(tag) > 0 {
+		return tag, true
+	}
+
+	if name, ok := gormSetting(f, "column"); ok && len(name) > 0 {
+		return name, true
+	}
+	if _, ok := gormSetting(f, "-"); ok {
+		return "-", true
+	}
+
+	return strings.ToLower(f.Name), false
+}
+
+// gormSetting returns the value of the setting of the field 'gorm' tag, e.g. column:name;primaryKey
+func gormSetting(f reflect.StructField, name string) (string, bool) {
+	for _, setting := range strings.Split(f.Tag.Get(gormTagName), ";") {
+		key, value := setting, ""
+		if x := strings.Index(setting, ":"); x >= 0 {
+			key, value = setting[:x], setting[x+1:]
+		}
+		if strings.EqualFold(strings.TrimSpace(key), name) {
+			return strings.TrimSpace(value), true
+		}
+	}
+	return "", false
+}
+
+// structValue returns the struct value of a struct or a pointer to a struct
+func structValue(item interface{}) (reflect.Value, error) {
+	if item == nil {
+		return reflect.Value{}, errors.New("aclsql: nil item")
+	}
+
+	v := reflect.ValueOf(item)
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return reflect.Value{}, errors.New("aclsql: nil " + v.Type().String())
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return reflect.Value{}, errors.New("aclsql: expecting struct, got " + v.Type().String())
+	}
+
+	return v, nil
+}
@@ -0,0 +1,141 @@
+// Copyright 2020 Alexander Zherdev. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package aclsql
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mralexzee/acllibgo"
+	"github.com/stretchr/testify/assert"
+)
+
+type User struct {
+	ID     string    `db:"id" aclowner:""`
+	Name   string    `db:"name"`
+	Email  string    `db:"email,omitempty" acl:"admin,self"`
+	Salary int       `db:"salary" acl:"hr"`
+	Hired  time.Time `db:"hired_at" acl:"hr"`
+	Age    int
+	Notes  string `db:"-"`
+	secret string
+	Audit
+	*Contact `acl:"admin"`
+}
+
+type Audit struct {
+	CreatedBy string `db:"created_by"`
+	UpdatedBy string `db:"updated_by" acl:"admin"`
+}
+
+type Contact struct {
+	Phone string `db:"phone"`
+}
+
+var hired = time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC)
+
+func newUser() User {
+	return User{
+		ID: "u1", Name: "John", Email: "john@example.com", Salary: 100, Hired: hired, Age: 30, Notes: "n", secret: "s",
+		Audit:   Audit{CreatedBy: "root", UpdatedBy: "jane"},
+		Contact: &Contact{Phone: "555"},
+	}
+}
+
+func Test_Columns(t *testing.T) {
+
+	user := newUser()
+	columns, values, err := Columns(&user, []string{"user"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"id", "name", "age", "created_by"}, columns)
+	assert.Equal(t, []interface{}{"u1", "John", 30, "root"}, values)
+	assert.Equal(t, newUser(), user)
+
+	columns, values, err = Columns(user, []string{"admin", "hr"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"id", "name", "email", "salary", "hired_at", "age", "created_by", "updated_by", "phone"}, columns)
+	assert.Equal(t, []interface{}{"u1", "John", "john@example.com", 100, hired, 30, "root", "jane", "555"}, values)
+
+	user.Contact = nil
+	columns, _, err = NewMapper([]string{}).WithSubject(acllibgo.Subject{ID: "u1", Groups: []string{"admin"}}).Columns(&user)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"id", "name", "email", "age", "created_by", "updated_by"}, columns)
+}
+
+func Test_Columns_Engine(t *testing.T) {
+
+	e := acllibgo.NewEngine(acllibgo.Options{DenyUntagged: true})
+	columns, values, err := NewMapper([]string{"admin"}).WithEngine(e).Columns(&Contact{Phone: "555"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{}, columns)
+	assert.Equal(t, []interface{}{}, values)
+}
+
+func Test_Columns_Errors(t *testing.T) {
+
+	var user *User
+	_, _, err := Columns(&User{}, nil)
+	assert.EqualError(t, err, "aclsql: nil acl")
+	_, _, err = Columns(nil, []string{})
+	assert.EqualError(t, err, "aclsql: nil item")
+	_, _, err = Columns(user, []string{})
+	assert.EqualError(t, err, "aclsql: nil *aclsql.User")
+	_, _, err = Columns(10, []string{})
+	assert.EqualError(t, err, "aclsql: expecting struct, got int")
+}
+
+func Test_Columns_Relations(t *testing.T) {
+
+	account := &Account{
+		ID: 7, Username: "jdoe", Created: hired,
+		Parent:  &Account{ID: 3},
+		Members: []*User{{ID: "u1"}},
+		Owners:  map[string]User{"u1": {ID: "u1"}},
+		Audit:   Audit{CreatedBy: "root"},
+	}
+
+	// relations are joins, not columns
+	columns, values, err := Columns(account, []string{"admin"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"id", "user_name", "balance", "created_at", "created_by", "updated_by"}, columns)
+	assert.Len(t, values, len(columns))
+
+	rows := queryTable(t, "accounts")
+	defer rows.Close()
+	assert.True(t, rows.Next())
+	scanned := Account{}
+	assert.NoError(t, Scan(rows, &scanned, []string{"admin"}))
+	assert.Equal(t, Account{ID: 7, Username: "jdoe", Balance: sql.NullInt64{Int64: 10, Valid: true}, Created: hired, Audit: Audit{CreatedBy: "root", UpdatedBy: "jane"}}, scanned)
+}
+
+// contactAdapter walks Contact through the adapter interface
+type contactAdapter struct {
+	acllibgo.StructAdapter
+}
+
+func (contactAdapter) Accepts(t reflect.Type) bool {
+	return t == reflect.TypeOf(Contact{})
+}
+
+func Test_Columns_Adapter(t *testing.T) {
+
+	e := acllibgo.NewEngine(acllibgo.Options{})
+	e.RegisterAdapter(contactAdapter{})
+	mapper := NewMapper([]string{"admin"}).WithEngine(e)
+
+	_, _, err := mapper.Columns(&Contact{Phone: "555"})
+	assert.EqualError(t, err, "aclsql: adapted type aclsql.Contact is not supported")
+	_, _, err = mapper.Columns(&User{ID: "u1", Contact: &Contact{Phone: "555"}})
+	assert.EqualError(t, err, "aclsql: adapted type aclsql.Contact is not supported")
+
+	rows := queryUsers(t)
+	defer rows.Close()
+	assert.True(t, rows.Next())
+	user := User{}
+	assert.EqualError(t, mapper.Scan(rows, &user), "aclsql: adapted type aclsql.Contact is not supported")
+	assert.Nil(t, user.Contact)
+}
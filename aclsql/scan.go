@@ -0,0 +1,143 @@
+// Copyright 2020 Alexander Zherdev. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package aclsql
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"strings"
+)
+
+// Scan reads the current row into the struct pointed to by dest, then clears the columns the groups
+// may not read. Columns are matched to fields ignoring case, result columns without a field are
+// discarded. Denied fields are set to their mask or zero value, struct values such as time.Time included,
+// and a denied embedded struct pointer is left nil as Scrub leaves it.
+func Scan(rows *sql.Rows, dest interface{}, groups []string) error {
+	if groups == nil {
+		return errors.New("aclsql: nil acl")
+	}
+	return NewMapper(groups).Scan(rows, dest)
+}
+
+// ScanAll reads the remaining rows into the slice pointed to by dest, see Scan
+// dest is a pointer to a slice of structs or of pointers to structs, rows are appended to it.
+func ScanAll(rows *sql.Rows, dest interface{}, groups []string) error {
+	if groups == nil {
+		return errors.New("aclsql: nil acl")
+	}
+	return NewMapper(groups).ScanAll(rows, dest)
+}
+
+// Scan reads the current row into the struct pointed to by dest for the subject, see Scan
+func (m *Mapper) Scan(rows *sql.Rows, dest interface{}) error {
+	if dest == nil || reflect.TypeOf(dest).Kind() != reflect.Ptr {
+		return errors.New("aclsql: expecting pointer to struct")
+	}
+
+	v, err := structValue(dest)
+	if err != nil {
+		return err
+	}
+
+	names, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	return m.scan(rows, names, v)
+}
+
+// ScanAll reads the remaining rows into the slice pointed to by dest for the subject, see ScanAll
+func (m *Mapper) ScanAll(rows *sql.Rows, dest interface{}) error {
+	v := reflect.ValueOf(dest)
+	if dest == nil || v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Slice {
+		return errors.New("aclsql: expecting pointer to slice")
+	}
+
+	slice := v.Elem()
+	elemType := slice.Type().Elem()
+	isPtr := elemType.Kind() == reflect.Ptr
+	if isPtr {
+		elemType = elemType.Elem()
+	}
+	if elemType.Kind() != reflect.Struct {
+		return errors.New("aclsql: expecting struct, got " + elemType.String())
+	}
+
+	names, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	for rows.Next() {
+		item := reflect.New(elemType)
+		if err := m.scan(rows, names, item.Elem()); err != nil {
+			return err
+		}
+
+		if isPtr {
+			slice.Set(reflect.Append(slice, item))
+		} else {
+			slice.Set(reflect.Append(slice, item.Elem()))
+		}
+	}
+
+	return rows.Err()
+}
+
+// scan reads the current row into the struct value v and clears its denied fields
+// The rules are evaluated once the row is read, with a new evaluation for every row.
+func (m *Mapper) scan(rows *sql.Rows, names []string, v reflect.Value) error {
+	columns, err := m.columns(m.evaluator(), v, true, true, nil)
+	if err != nil {
+		return err
+	}
+
+	fields := make(map[string]reflect.Value)
+	for _, c := range columns {
+		if c.embedded {
+			continue
+		}
+		name := strings.ToLower(c.name)
+		if _, found := fields[name]; !found {
+			fields[name] = c.value
+		}
+	}
+
+	targets := make([]interface{}, len(names))
+	for x, name := range names {
+		if fv, found := fields[strings.ToLower(name)]; found {
+			targets[x] = fv.Addr().Interface()
+		} else {
+			targets[x] = new(interface{})
+		}
+	}
+
+	if err := rows.Scan(targets...); err != nil {
+		return err
+	}
+
+	if columns, err = m.columns(m.evaluator(), v, false, true, nil); err != nil {
+		return err
+	}
+	for _, c := range columns {
+		if c.kept {
+			continue
+		}
+		if c.embedded {
+			// the columns of the embedded struct are denied with it, they are listed next
+			c.value.Set(reflect.Zero(c.value.Type()))
+			continue
+		}
+		if len(c.plan.Mask) > 0 && c.value.Kind() == reflect.String {
+			c.value.SetString(c.plan.Mask)
+		} else {
+			c.value.Set(reflect.Zero(c.value.Type()))
+		}
+	}
+
+	return nil
+}
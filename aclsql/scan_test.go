@@ -0,0 +1,174 @@
+// Copyright 2020 Alexander Zherdev. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package aclsql
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/mralexzee/acllibgo"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeDriver serves the tables of fakeTables, the query text names the table
+type fakeDriver struct{}
+
+type fakeConn struct{}
+
+type fakeStmt struct {
+	table string
+}
+
+type fakeRows struct {
+	table fakeTable
+	next  int
+}
+
+type fakeTable struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+var fakeTables = map[string]fakeTable{
+	"users": {
+		columns: []string{"id", "name", "EMAIL", "salary", "hired_at", "age", "created_by", "updated_by", "phone", "extra"},
+		rows: [][]driver.Value{
+			{"u1", "John", "john@example.com", int64(100), hired, int64(30), "root", "jane", "555", "x"},
+			{"u2", "Jane", "jane@example.com", int64(200), hired, int64(40), "root", "john", "556", "y"},
+		},
+	},
+	"accounts": {
+		columns: []string{"id", "user_name", "balance", "created_at", "parent_id", "owners", "created_by", "updated_by"},
+		rows: [][]driver.Value{
+			{int64(7), "jdoe", int64(10), hired, int64(3), "x", "root", "jane"},
+		},
+	},
+}
+
+func init() {
+	sql.Register("aclsqlfake", fakeDriver{})
+}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) { return fakeConn{}, nil }
+
+func (fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{table: query}, nil }
+func (fakeConn) Close() error                              { return nil }
+func (fakeConn) Begin() (driver.Tx, error)                 { return nil, errors.New("fake: no transactions") }
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return 0 }
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, errors.New("fake: read only")
+}
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	table, ok := fakeTables[s.table]
+	if !ok {
+		return nil, errors.New("fake: unknown table " + s.table)
+	}
+	return &fakeRows{table: table}, nil
+}
+
+func (r *fakeRows) Columns() []string { return r.table.columns }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.table.rows) {
+		return io.EOF
+	}
+	copy(dest, r.table.rows[r.next])
+	r.next++
+	return nil
+}
+
+func queryUsers(t *testing.T) *sql.Rows {
+	return queryTable(t, "users")
+}
+
+func queryTable(t *testing.T, table string) *sql.Rows {
+	db, err := sql.Open("aclsqlfake", "")
+	assert.NoError(t, err)
+	rows, err := db.Query(table)
+	assert.NoError(t, err)
+	return rows
+}
+
+func Test_Scan(t *testing.T) {
+
+	rows := queryUsers(t)
+	defer rows.Close()
+
+	assert.True(t, rows.Next())
+	user := User{Notes: "kept"}
+	assert.NoError(t, Scan(rows, &user, []string{"hr"}))
+	assert.Equal(t, User{ID: "u1", Name: "John", Salary: 100, Hired: hired, Age: 30, Notes: "kept", Audit: Audit{CreatedBy: "root"}}, user)
+
+	assert.True(t, rows.Next())
+	user = User{}
+	assert.NoError(t, NewMapper([]string{}).WithSubject(acllibgo.Subject{ID: "u2", Groups: []string{"admin"}}).Scan(rows, &user))
+	assert.Equal(t, User{ID: "u2", Name: "Jane", Email: "jane@example.com", Age: 40, Audit: Audit{CreatedBy: "root", UpdatedBy: "john"}, Contact: &Contact{Phone: "556"}}, user)
+	assert.Equal(t, time.Time{}, user.Hired)
+}
+
+func Test_Scan_DeniedEmbeddedPointer(t *testing.T) {
+
+	rows := queryUsers(t)
+	defer rows.Close()
+
+	// a denied embedded pointer is left nil like Scrub leaves it, even when dest had one
+	assert.True(t, rows.Next())
+	user := User{Contact: &Contact{Phone: "999"}}
+	assert.NoError(t, Scan(rows, &user, []string{"hr"}))
+	assert.Nil(t, user.Contact)
+
+	assert.True(t, rows.Next())
+	user = User{Contact: &Contact{Phone: "999"}}
+	assert.NoError(t, Scan(rows, &user, []string{"admin"}))
+	assert.Equal(t, &Contact{Phone: "556"}, user.Contact)
+}
+
+func Test_ScanAll(t *testing.T) {
+
+	rows := queryUsers(t)
+	defer rows.Close()
+
+	users := []*User{}
+	assert.NoError(t, NewMapper([]string{}).WithSubject(acllibgo.Subject{ID: "u2"}).ScanAll(rows, &users))
+	assert.Len(t, users, 2)
+	assert.Equal(t, "", users[0].Email)
+	assert.Equal(t, "jane@example.com", users[1].Email)
+	assert.Equal(t, 0, users[1].Salary)
+
+	rows = queryUsers(t)
+	defer rows.Close()
+
+	values := []User{}
+	assert.NoError(t, ScanAll(rows, &values, []string{"hr"}))
+	assert.Equal(t, []int{100, 200}, []int{values[0].Salary, values[1].Salary})
+	assert.Equal(t, "", values[1].UpdatedBy)
+}
+
+func Test_Scan_Errors(t *testing.T) {
+
+	rows := queryUsers(t)
+	defer rows.Close()
+	assert.True(t, rows.Next())
+
+	user := User{}
+	assert.EqualError(t, Scan(rows, &user, nil), "aclsql: nil acl")
+	assert.EqualError(t, Scan(rows, user, []string{}), "aclsql: expecting pointer to struct")
+	assert.EqualError(t, ScanAll(rows, &user, []string{}), "aclsql: expecting pointer to slice")
+	assert.EqualError(t, ScanAll(rows, &[]int{}, []string{}), "aclsql: expecting struct, got int")
+
+	// columns without a field are discarded
+	contact := Contact{}
+	assert.NoError(t, Scan(rows, &contact, []string{}))
+	assert.Equal(t, "555", contact.Phone)
+	contacts := []Contact{}
+	assert.NoError(t, ScanAll(rows, &contacts, []string{}))
+	assert.Equal(t, []Contact{{Phone: "556"}}, contacts)
+}
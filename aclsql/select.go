@@ -0,0 +1,188 @@
+// Copyright 2020 Alexander Zherdev. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package aclsql
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/mralexzee/acllibgo"
+)
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	valuerType  = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+
+	// selectAll selects every field of a struct, like the * selector
+	selectAll = []acllibgo.StructField{{Name: "*"}}
+)
+
+// Selection lists the columns of a struct type a Keep selector preserves
+type Selection struct {
+	Columns []string
+	Joins   []Join
+}
+
+// Join is a hint to load a related struct, a field holding pointers, slices, or maps of structs
+type Join struct {
+	// Field is the Go name of the field holding the related structs
+	Field string
+	// Column is the column name of the field, from its 'db' or gorm tag
+	Column string
+	// Type is the related struct type
+	Type reflect.Type
+	// Many is set for slices, arrays, and maps of structs
+	Many bool
+	// Selection lists the columns of the related type the selector preserves
+	Selection
+}
+
+// Select returns the columns of the struct type that Keep preserves for the selector, so a query can
+// read only those. Fields match the selector names like Keep, ignoring case, and * selects every field.
+// A selected relation becomes a join hint with the columns of its own selector, a relation selected
+// without one is kept whole and lists every column of the related type. A whole relation of a type
+// already selected whole further up is not repeated, so self-referencing types end. Embedded structs
+// contribute their columns, and selector names matching no field are reported.
+//
+//	fields, _ := acllibgo.Parse("id,name,account(username,parent(id))")
+//	selection, err := aclsql.Select(reflect.TypeOf(User{}), fields)
+func Select(t reflect.Type, fields []acllibgo.StructField) (*Selection, error) {
+	if t == nil {
+		return nil, errors.New("aclsql: nil type")
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, errors.New("aclsql: expecting struct, got " + t.String())
+	}
+
+	problems := []string{}
+	rv := selectType(t, fields, "", nil, &problems)
+	if len(problems) > 0 {
+		return nil, errors.New("aclsql: " + t.String() + ": " + strings.Join(problems, "; "))
+	}
+
+	return &rv, nil
+}
+
+// selectType collects the columns and joins of the struct type for the selector
+// whole lists the related types being selected whole, a relation to one of them is left out.
+func selectType(t reflect.Type, fields []acllibgo.StructField, path string, whole []reflect.Type, problems *[]string) Selection {
+	rv := Selection{Columns: []string{}, Joins: []Join{}}
+	used := make([]bool, len(fields))
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if len(f.PkgPath) > 0 {
+			continue
+		}
+
+		name, tagged := columnName(f)
+		if name == "-" {
+			continue
+		}
+
+		found := -1
+		for x, k := range fields {
+			if strings.EqualFold(k.Name, f.Name) || k.Name == "*" {
+				used[x] = true
+				found = x
+				break
+			}
+		}
+		if found < 0 {
+			continue
+		}
+		children := fields[found].Fields
+
+		if f.Anonymous && !tagged {
+			// a value struct is kept whole, Keep descends into a pointer with the children
+			var embedded Selection
+			switch {
+			case f.Type.Kind() == reflect.Struct && !isColumnType(f.Type):
+				embedded = selectType(f.Type, selectAll, path, whole, problems)
+			case f.Type.Kind() == reflect.Ptr && f.Type.Elem().Kind() == reflect.Struct && !isColumnType(f.Type.Elem()):
+				embedded = selectType(f.Type.Elem(), children, path+f.Name+".", whole, problems)
+			default:
+				rv.Columns = append(rv.Columns, name)
+				continue
+			}
+			rv.Columns = append(rv.Columns, embedded.Columns...)
+			rv.Joins = append(rv.Joins, embedded.Joins...)
+			continue
+		}
+
+		if related, many, ok := relation(f.Type); ok {
+			nested := whole
+			if children == nil {
+				// Keep keeps a relation without a selector whole
+				if containsType(whole, related) {
+					continue
+				}
+				children = selectAll
+				nested = append(append([]reflect.Type{}, whole...), related)
+			}
+			rv.Joins = append(rv.Joins, Join{
+				Field:     f.Name,
+				Column:    name,
+				Type:      related,
+				Many:      many,
+				Selection: selectType(related, children, path+f.Name+".", nested, problems),
+			})
+			continue
+		}
+
+		rv.Columns = append(rv.Columns, name)
+	}
+
+	for x, k := range fields {
+		if !used[x] && k.Name != "*" {
+			*problems = append(*problems, "unknown field "+path+k.Name)
+		}
+	}
+
+	return rv
+}
+
+func containsType(types []reflect.Type, t reflect.Type) bool {
+	for _, x := range types {
+		if x == t {
+			return true
+		}
+	}
+	return false
+}
+
+// relation returns the struct type held by pointers, slices, arrays, or maps of the field type
+func relation(t reflect.Type) (reflect.Type, bool, bool) {
+	many := false
+	switch t.Kind() {
+	case reflect.Ptr:
+	case reflect.Slice, reflect.Array, reflect.Map:
+		many = true
+	default:
+		return nil, false, false
+	}
+
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || isColumnType(t) {
+		return nil, false, false
+	}
+
+	return t, many, true
+}
+
+// isColumnType reports whether values of the struct type are stored in a single column, e.g. time.Time
+func isColumnType(t reflect.Type) bool {
+	return t == timeType || reflect.PtrTo(t).Implements(scannerType) || t.Implements(valuerType)
+}
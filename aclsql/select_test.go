@@ -0,0 +1,104 @@
+// Copyright 2020 Alexander Zherdev. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package aclsql
+
+import (
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mralexzee/acllibgo"
+	"github.com/stretchr/testify/assert"
+)
+
+type Account struct {
+	ID       int64         `gorm:"column:id;primaryKey"`
+	Username string        `db:"user_name"`
+	Balance  sql.NullInt64 `db:"balance"`
+	Created  time.Time     `gorm:"column:created_at"`
+	Secret   string        `gorm:"-"`
+	Parent   *Account      `db:"parent_id"`
+	Members  []*User       `db:"-"`
+	Owners   map[string]User
+	Audit
+}
+
+func parseFields(t *testing.T, text string) []acllibgo.StructField {
+	fields, err := acllibgo.Parse(text)
+	assert.NoError(t, err)
+	return fields
+}
+
+func Test_Select(t *testing.T) {
+
+	selection, err := Select(reflect.TypeOf(&Account{}), parseFields(t, "id,USERNAME,balance,created,parent(username,parent(id)),owners(name,contact(phone)),audit"))
+	assert.NoError(t, err)
+	assert.Equal(t, &Selection{
+		Columns: []string{"id", "user_name", "balance", "created_at", "created_by", "updated_by"},
+		Joins: []Join{
+			{Field: "Parent", Column: "parent_id", Type: reflect.TypeOf(Account{}), Selection: Selection{
+				Columns: []string{"user_name"},
+				Joins:   []Join{{Field: "Parent", Column: "parent_id", Type: reflect.TypeOf(Account{}), Selection: Selection{Columns: []string{"id"}, Joins: []Join{}}}},
+			}},
+			{Field: "Owners", Column: "owners", Type: reflect.TypeOf(User{}), Many: true, Selection: Selection{Columns: []string{"name", "phone"}, Joins: []Join{}}},
+		},
+	}, selection)
+}
+
+func Test_Select_All(t *testing.T) {
+
+	selection, err := Select(reflect.TypeOf(User{}), parseFields(t, "*"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"id", "name", "email", "salary", "hired_at", "age", "created_by", "updated_by"}, selection.Columns)
+	assert.Empty(t, selection.Joins)
+
+	// a relation selected without a selector of its own is kept whole, the nested Parent ends the cycle
+	users := Selection{Columns: []string{"id", "name", "email", "salary", "hired_at", "age", "created_by", "updated_by"}, Joins: []Join{}}
+	selection, err = Select(reflect.TypeOf(Account{}), parseFields(t, "id,parent"))
+	assert.NoError(t, err)
+	assert.Equal(t, &Selection{
+		Columns: []string{"id"},
+		Joins: []Join{
+			{Field: "Parent", Column: "parent_id", Type: reflect.TypeOf(Account{}), Selection: Selection{
+				Columns: []string{"id", "user_name", "balance", "created_at", "created_by", "updated_by"},
+				Joins:   []Join{{Field: "Owners", Column: "owners", Type: reflect.TypeOf(User{}), Many: true, Selection: users}},
+			}},
+		},
+	}, selection)
+}
+
+func Test_Select_KeepParity(t *testing.T) {
+
+	fields := parseFields(t, "id,name,salary,contact(phone)")
+	user := newUser()
+	assert.NoError(t, acllibgo.Keep(&user, fields))
+
+	selection, err := Select(reflect.TypeOf(User{}), fields)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"id", "name", "salary", "phone"}, selection.Columns)
+
+	// every selected column holds a value Keep preserved
+	columns, values, err := Columns(&user, []string{"admin", "hr"})
+	assert.NoError(t, err)
+	preserved := map[string]bool{}
+	for x, value := range values {
+		preserved[columns[x]] = !reflect.ValueOf(value).IsZero()
+	}
+	for _, column := range selection.Columns {
+		assert.True(t, preserved[column], column)
+	}
+	assert.False(t, preserved["email"])
+}
+
+func Test_Select_Errors(t *testing.T) {
+
+	_, err := Select(nil, nil)
+	assert.EqualError(t, err, "aclsql: nil type")
+	_, err = Select(reflect.TypeOf(1), nil)
+	assert.EqualError(t, err, "aclsql: expecting struct, got int")
+	_, err = Select(reflect.TypeOf(Account{}), parseFields(t, "id,secret,members,parent(usrname),createdby"))
+	assert.EqualError(t, err, "aclsql: aclsql.Account: unknown field Parent.usrname; unknown field secret; unknown field members; unknown field createdby")
+}
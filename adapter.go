@@ -0,0 +1,198 @@
+// Copyright 2020 Alexander Zherdev. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package acllibgo
+
+import (
+	"reflect"
+)
+
+// Adapter exposes the fields of an object model to the engine traversals, for models that are not plain
+// structs such as generated protobuf messages holding internal state the traversal must not touch
+// The engine asks the registered adapters, in order, whether they accept a type before falling back to
+// the struct fields and their tags; policies do not apply to adapted types, the adapter provides the
+// rules. The traversals of the engine read and write adapted fields through the adapter, as do acljson,
+// acllog and aclexport through the Evaluator. aclsql maps columns from struct tags and rejects them.
+type Adapter interface {
+	// Accepts reports whether the adapter handles objects of the type, the type a pointer refers to
+	Accepts(t reflect.Type) bool
+	// Fields lists the fields of the type the rules apply to, together with their rules
+	Fields(t reflect.Type) []AdapterField
+	// Get returns the value of the field of obj, index is the AdapterField.Index
+	Get(obj reflect.Value, index int) reflect.Value
+	// Set stores value in the field of obj, used to mask denied fields
+	Set(obj reflect.Value, index int, value reflect.Value)
+	// Clear resets the field of obj, used to clear denied fields
+	Clear(obj reflect.Value, index int)
+}
+
+// AdapterField describes a field exposed by an adapter and the options its rules come from
+type AdapterField struct {
+	// Name is the field name used in paths, selectors and explanations
+	Name string
+	// JsonName is the name encoded by encoding/json and matched by conditions, Name when empty
+	JsonName string
+	Type     reflect.Type
+	// Index identifies the field for Get, Set and Clear
+	Index int
+	// Acl is the rule of the field, same as an 'acl' tag
+	Acl string
+	// Mask replaces the value of the denied string field instead of clearing it
+	Mask string
+	// Omit leaves the denied field out of copies and encodings, same as aclmode:"omit"
+	Omit bool
+	// Owner marks the field holding the owner of the object, same as the 'aclowner' tag
+	Owner bool
+}
+
+// StructAdapter walks a plain struct the way the engine does without adapters
+// Unexported fields are skipped and the rules come from the 'acl', 'aclmode' and 'aclowner' tags.
+// Embed it to adapt struct-based models, overriding Accepts and Fields.
+type StructAdapter struct{}
+
+// Accepts reports whether the type is a struct
+func (StructAdapter) Accepts(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct
+}
+
+// Fields lists the exported fields of the struct type
+func (StructAdapter) Fields(t reflect.Type) []AdapterField {
+	info := getTypeInfo(t)
+	rv := make([]AdapterField, 0, len(info.Field))
+	for x, f := range info.Field {
+		if !f.Exported {
+			continue
+		}
+		rv = append(rv, AdapterField{
+			Name:     f.Name,
+			JsonName: f.JsonName,
+			Type:     f.Type,
+			Index:    x,
+			Acl:      f.AclRule,
+			Mask:     f.Mask,
+			Omit:     f.Omit,
+			Owner:    x == info.OwnerField,
+		})
+	}
+
+	return rv
+}
+
+// Get returns the struct field at the index
+func (StructAdapter) Get(obj reflect.Value, index int) reflect.Value {
+	return obj.Field(index)
+}
+
+// Set stores the value in the struct field at the index
+func (StructAdapter) Set(obj reflect.Value, index int, value reflect.Value) {
+	obj.Field(index).Set(value)
+}
+
+// Clear resets the struct field at the index as Scrub does
+func (StructAdapter) Clear(obj reflect.Value, index int) {
+	clearDenied(obj.Field(index))
+}
+
+// RegisterAdapter adds the adapter to the engine, adapters registered first take precedence
+// The compiled per-type plans are discarded, calls in flight finish with the adapters they started with.
+func (e *Engine) RegisterAdapter(adapter Adapter) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	s := e.currentState()
+	adapters := append(append([]Adapter{}, s.adapters...), adapter)
+	e.state.Store(newPolicyState(e.groups, adapters, s.policy, s.version, s.rules))
+}
+
+// adapter returns the first registered adapter accepting the type, nil when there is none
+func (s *policyState) adapter(t reflect.Type) Adapter {
+	for _, a := range s.adapters {
+		if a.Accepts(t) {
+			return a
+		}
+	}
+	return nil
+}
+
+// adaptedTypeInfo builds the type information of the type from the fields listed by the adapter
+func adaptedTypeInfo(t reflect.Type, adapter Adapter) typeInfo {
+	rv := typeInfo{
+		Name:          t.Name(),
+		PrkPath:       t.PkgPath(),
+		ToStringValue: t.String(),
+		Kind:          t.Kind(),
+		OwnerField:    -1,
+		adapter:       adapter,
+	}
+
+	fields := adapter.Fields(t)
+	rv.Field = make([]fieldInfo, len(fields))
+	for x, af := range fields {
+		f := fieldInfo{
+			Name:     af.Name,
+			Kind:     af.Type.Kind(),
+			Type:     af.Type,
+			Exported: true,
+			JsonName: af.JsonName,
+			Mask:     af.Mask,
+			Omit:     af.Omit,
+			Index:    af.Index,
+		}
+		if len(f.JsonName) == 0 {
+			f.JsonName = af.Name
+		}
+		f.setRule(af.Acl, SourceAdapter)
+		if af.Owner && rv.OwnerField < 0 {
+			rv.OwnerField = x
+		}
+		rv.Field[x] = f
+	}
+
+	return rv
+}
+
+// value returns the field at index i of the object
+func (t *typeInfo) value(obj reflect.Value, i int) reflect.Value {
+	if t.adapter != nil {
+		return t.adapter.Get(obj, t.Field[i].Index)
+	}
+	return obj.Field(i)
+}
+
+// set stores the value in the field at index i of the object
+func (t *typeInfo) set(obj reflect.Value, i int, value reflect.Value) {
+	if t.adapter != nil {
+		t.adapter.Set(obj, t.Field[i].Index, value)
+		return
+	}
+	obj.Field(i).Set(value)
+}
+
+// clear resets the field at index i of the object
+func (t *typeInfo) clear(obj reflect.Value, i int) {
+	if t.adapter != nil {
+		t.adapter.Clear(obj, t.Field[i].Index)
+		return
+	}
+	clearDenied(obj.Field(i))
+}
+
+// mask sets the string field at index i of the object to the mask, reports false when it cannot
+func (t *typeInfo) mask(obj reflect.Value, i int, mask string) bool {
+	f := t.Field[i]
+	if f.Kind != reflect.String {
+		return false
+	}
+	if t.adapter != nil {
+		t.adapter.Set(obj, f.Index, reflect.ValueOf(mask).Convert(f.Type))
+		return true
+	}
+
+	v := obj.Field(i)
+	if !v.CanSet() {
+		return false
+	}
+	v.SetString(mask)
+	return true
+}
@@ -0,0 +1,219 @@
+// Copyright 2020 Alexander Zherdev. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package acllibgo
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// protoMessage is implemented by generated messages
+type protoMessage interface {
+	ProtoMessage()
+}
+
+type messageState struct {
+	initialized bool
+}
+
+// Customer models a generated message, the internal fields must survive a Scrub
+type Customer struct {
+	state         messageState
+	sizeCache     int32
+	unknownFields []byte
+
+	Id    string       `protobuf:"bytes,1,opt,name=id,proto3"`
+	Email string       `protobuf:"bytes,2,opt,name=email,proto3"`
+	Phone string       `protobuf:"bytes,3,opt,name=phone,proto3"`
+	Card  *PaymentCard `protobuf:"bytes,4,opt,name=card,proto3"`
+}
+
+type PaymentCard struct {
+	state messageState
+
+	Number string `protobuf:"bytes,1,opt,name=number,proto3"`
+	Brand  string `protobuf:"bytes,2,opt,name=brand,proto3"`
+}
+
+func (*Customer) ProtoMessage()    {}
+func (*PaymentCard) ProtoMessage() {}
+
+type fieldOption struct {
+	acl   string
+	mask  string
+	owner bool
+}
+
+// protoAdapter reads the rules from field options keyed by message and field name instead of tags
+type protoAdapter struct {
+	StructAdapter
+	options map[string]fieldOption
+}
+
+var protoMessageType = reflect.TypeOf((*protoMessage)(nil)).Elem()
+
+func (a protoAdapter) Accepts(t reflect.Type) bool {
+	return reflect.PtrTo(t).Implements(protoMessageType)
+}
+
+func (a protoAdapter) Fields(t reflect.Type) []AdapterField {
+	rv := []AdapterField{}
+	for x := 0; x < t.NumField(); x++ {
+		field := t.Field(x)
+		tag, ok := field.Tag.Lookup("protobuf")
+		if !ok {
+			continue
+		}
+
+		name := tag[strings.Index(tag, "name=")+5:]
+		name = name[:strings.Index(name, ",")]
+		option := a.options[t.Name()+"."+name]
+		rv = append(rv, AdapterField{Name: name, Type: field.Type, Index: x, Acl: option.acl, Mask: option.mask, Owner: option.owner})
+	}
+	return rv
+}
+
+func (a protoAdapter) Clear(obj reflect.Value, index int) {
+	field := obj.Field(index)
+	field.Set(reflect.Zero(field.Type()))
+}
+
+func newCustomer() *Customer {
+	return &Customer{
+		state: messageState{initialized: true}, sizeCache: 42, unknownFields: []byte{1},
+		Id: "c1", Email: "john@example.com", Phone: "555",
+		Card: &PaymentCard{state: messageState{initialized: true}, Number: "4111", Brand: "visa"},
+	}
+}
+
+func newAdapterEngine() *Engine {
+	e := NewEngine(Options{})
+	e.RegisterAdapter(protoAdapter{options: map[string]fieldOption{
+		"Customer.id":        {acl: "public", owner: true},
+		"Customer.email":     {acl: "support,self"},
+		"Customer.phone":     {acl: "support", mask: "***"},
+		"Customer.card":      {acl: "billing,support"},
+		"PaymentCard.number": {acl: "billing"},
+	}})
+	return e
+}
+
+func Test_Adapter_Scrub(t *testing.T) {
+
+	e := newAdapterEngine()
+
+	customer := newCustomer()
+	report, err := e.ScrubWithReport(customer, []string{"billing"})
+	assert.NoError(t, err)
+	assert.Equal(t, &Customer{
+		state: messageState{initialized: true}, sizeCache: 42, unknownFields: []byte{1},
+		Id: "c1", Phone: "***",
+		Card: &PaymentCard{state: messageState{initialized: true}, Number: "4111", Brand: "visa"},
+	}, customer)
+	assert.Equal(t, []Redaction{
+		{Path: "email", Rule: `acl:"support,self"`, Action: ActionZero},
+		{Path: "phone", Rule: `acl:"support"`, Action: ActionMask},
+	}, report)
+
+	customer = newCustomer()
+	assert.NoError(t, e.ScrubSubject(customer, Subject{ID: "c1", Groups: []string{"support"}}))
+	assert.Equal(t, "john@example.com", customer.Email)
+	assert.Equal(t, "555", customer.Phone)
+	assert.Equal(t, &PaymentCard{state: messageState{initialized: true}, Brand: "visa"}, customer.Card)
+
+	// the default engine knows nothing of the adapter
+	customer = newCustomer()
+	assert.NoError(t, Scrub(customer, []string{}))
+	assert.Equal(t, newCustomer(), customer)
+}
+
+func Test_Adapter_Explain(t *testing.T) {
+
+	e := newAdapterEngine()
+
+	explanation, err := e.Explain(newCustomer(), []string{"support"})
+	assert.NoError(t, err)
+	assert.Equal(t, Explanation{
+		{Path: "id", Kept: true, Reason: ReasonPublic, Rule: `acl:"public"`, Source: SourceAdapter},
+		{Path: "email", Kept: true, Reason: ReasonGroup, Group: "support", Rule: `acl:"support,self"`, Source: SourceAdapter},
+		{Path: "phone", Kept: true, Reason: ReasonGroup, Group: "support", Rule: `acl:"support"`, Source: SourceAdapter},
+		{Path: "card", Kept: true, Reason: ReasonGroup, Group: "support", Rule: `acl:"billing,support"`, Source: SourceAdapter},
+		{Path: "card.number", Kept: false, Action: ActionZero, Reason: ReasonNoMatch, Rule: `acl:"billing"`, Source: SourceAdapter},
+		{Path: "card.brand", Kept: true, Reason: ReasonNoTag},
+	}, explanation)
+
+	assert.Equal(t, []StructField{{Name: "id"}, {Name: "card", Fields: []StructField{{Name: "number"}, {Name: "brand"}}}},
+		e.VisibleFields(reflect.TypeOf(Customer{}), []string{"billing"}))
+}
+
+func Test_Adapter_Evaluator(t *testing.T) {
+
+	e := newAdapterEngine()
+	customer := newCustomer()
+	v := reflect.ValueOf(customer).Elem()
+	ev := e.NewEvaluator(Subject{})
+
+	fields := ev.Fields(v.Type())
+	assert.Len(t, fields, 4)
+	assert.Equal(t, "email", fields[1].Name)
+	assert.Equal(t, "john@example.com", ev.Value(v.Type(), v, 1).Interface())
+	assert.False(t, ev.Kept(v.Type(), v, 1))
+	assert.Equal(t, "***", ev.Redacted(v.Type(), v, 2).Interface())
+	assert.Equal(t, newCustomer(), customer)
+	assert.True(t, ev.Adapted(v.Type()))
+	assert.False(t, ev.Adapted(reflect.TypeOf(Person{})))
+
+	ev.SetValue(v.Type(), v, 0, reflect.ValueOf("c2"))
+	ev.Redact(v.Type(), v, 1)
+	ev.Redact(v.Type(), v, 2)
+	assert.Equal(t, "c2", customer.Id)
+	assert.Empty(t, customer.Email)
+	assert.Equal(t, "***", customer.Phone)
+	assert.Equal(t, int32(42), customer.sizeCache)
+}
+
+func Test_Adapter_Project(t *testing.T) {
+
+	e := newAdapterEngine()
+
+	customer := newCustomer()
+	projected, err := e.Project(customer, []string{"billing"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"id": "c1", "email": "", "phone": "***",
+		"card": map[string]interface{}{"number": "4111", "brand": "visa"},
+	}, projected)
+	assert.Equal(t, newCustomer(), customer)
+}
+
+func Test_Adapter_Redacted(t *testing.T) {
+
+	e := newAdapterEngine()
+
+	customer := newCustomer()
+	assert.Equal(t, fmt.Sprintf("{id:c1 email:%s phone:*** card:%p}", RedactedPlaceholder, customer.Card),
+		fmt.Sprintf("%+v", e.Redacted(*customer, []string{"billing"})))
+	assert.Equal(t, "{number:[REDACTED] brand:visa}", fmt.Sprintf("%+v", e.Redacted(*customer.Card, []string{})))
+}
+
+func Test_StructAdapter(t *testing.T) {
+
+	e := NewEngine(Options{})
+	e.RegisterAdapter(StructAdapter{})
+
+	person := newPerson()
+	expected := person
+	assert.NoError(t, Scrub(&expected, []string{"user"}))
+	assert.NoError(t, e.Scrub(&person, []string{"user"}))
+	assert.Equal(t, expected, person)
+
+	fields := StructAdapter{}.Fields(reflect.TypeOf(Customer{}))
+	assert.Equal(t, []string{"Id", "Email", "Phone", "Card"}, []string{fields[0].Name, fields[1].Name, fields[2].Name, fields[3].Name})
+	assert.Equal(t, 3, fields[0].Index)
+}
@@ -12,7 +12,23 @@ import (
 
 const tagName string = "acl"
 
-var _typeCache map[string]typeInfo
+// ownerTagName marks the field holding the owner of the struct, matched by the 'self' rule
+const ownerTagName string = "aclowner"
+
+// modeTagName selects how a denied field is redacted in copies and encodings, see ModeOmit
+const modeTagName string = "aclmode"
+
+const (
+	// ModeZero a denied field is cleared, or masked, and still present in the output
+	ModeZero string = "zero"
+	// ModeOmit a denied field is left out of the output of copies and encoders, Scrub still clears it
+	ModeOmit string = "omit"
+)
+
+// defaultFieldName is the blank marker field carrying the struct-level default 'acl' tag, e.g. _ struct{} `acl:"admin"`
+const defaultFieldName string = "_"
+
+var _typeCache map[reflect.Type]typeInfo
 var _cacheSync *sync.Mutex
 
 type typeInfo struct {
@@ -20,18 +36,37 @@ type typeInfo struct {
 	PrkPath       string
 	ToStringValue string
 	Kind          reflect.Kind
+	DefaultAcl    string
+	OwnerField    int
 	Field         []fieldInfo
+	adapter       Adapter
 }
 
 type fieldInfo struct {
-	Name    string
-	Kind    reflect.Kind
-	AclTags []string
+	Name        string
+	Kind        reflect.Kind
+	Type        reflect.Type
+	Exported    bool
+	Anonymous   bool
+	JsonName    string
+	OmitEmpty   bool
+	AclRule     string
+	AclTerms    []aclTerm
+	AclSource   Source
+	AclBits     bitset
+	AclPatterns bool
+	Mask        string
+	Omit        bool
+	// Index identifies the field for the adapter of the type
+	Index int
+	// AclKeywords and AclPredicates summarize the terms so decisions skip the ones that cannot match
+	AclKeywords   aclKeywords
+	AclPredicates bool
 }
 
 func init() {
 	_cacheSync = new(sync.Mutex)
-	_typeCache = make(map[string]typeInfo)
+	_typeCache = make(map[reflect.Type]typeInfo)
 }
 
 func getTypeInfo(itemType reflect.Type) typeInfo {
@@ -39,16 +74,13 @@ func getTypeInfo(itemType reflect.Type) typeInfo {
 		return typeInfo{}
 	}
 
-	// type key
-	k := itemType.PkgPath() + ":" + itemType.Name()
-
 	// find in cache - thread-safe
 	// we lock entire call to prevent run-on by multiple CPUs
 	_cacheSync.Lock()
 	defer _cacheSync.Unlock()
 
 	// if found in cache, return copy of cached data
-	if item, ok := _typeCache[k]; ok {
+	if item, ok := _typeCache[itemType]; ok {
 		return item
 	}
 
@@ -58,8 +90,18 @@ func getTypeInfo(itemType reflect.Type) typeInfo {
 	rv.PrkPath = itemType.PkgPath()
 	rv.ToStringValue = itemType.String()
 	rv.Kind = itemType.Kind()
+	rv.OwnerField = -1
 
 	if rv.Kind == reflect.Struct {
+		for x := 0; x < itemType.NumField(); x++ {
+			field := itemType.Field(x)
+			aclTag := strings.TrimSpace(field.Tag.Get(tagName))
+			if field.Name == defaultFieldName && len(aclTag) > 0 {
+				rv.DefaultAcl = aclTag
+				break
+			}
+		}
+
 		rv.Field = make([]fieldInfo, itemType.NumField())
 
 		for x := 0; x < len(rv.Field); x++ {
@@ -67,14 +109,27 @@ func getTypeInfo(itemType reflect.Type) typeInfo {
 			delta := fieldInfo{}
 			delta.Name = field.Name
 			delta.Kind = field.Type.Kind()
+			delta.Type = field.Type
+			delta.Exported = len(field.PkgPath) == 0
+			delta.Anonymous = field.Anonymous
+			delta.JsonName, delta.OmitEmpty = parseJsonTag(field)
+			delta.Omit = strings.TrimSpace(field.Tag.Get(modeTagName)) == ModeOmit
+
+			if _, ok := field.Tag.Lookup(ownerTagName); ok && rv.OwnerField < 0 {
+				rv.OwnerField = x
+			}
 
 			aclTag := strings.TrimSpace(field.Tag.Get(tagName))
 
+			// fields without their own tag inherit the struct default
 			if len(aclTag) > 0 {
-				delta.AclTags = strings.Split(aclTag, ",")
-			} else {
-				delta.AclTags = []string{}
+				delta.AclRule = aclTag
+				delta.AclSource = SourceTag
+			} else if len(rv.DefaultAcl) > 0 {
+				delta.AclRule = rv.DefaultAcl
+				delta.AclSource = SourceDefault
 			}
+			delta.AclTerms = parseAcl(delta.AclRule)
 
 			rv.Field[x] = delta
 		}
@@ -83,7 +138,51 @@ func getTypeInfo(itemType reflect.Type) typeInfo {
 	}
 
 	// save in cache map
-	_typeCache[k] = rv
+	_typeCache[itemType] = rv
 
 	return rv
 }
+
+// parseJsonTag returns the name encoding/json uses for the field ("-" when the field is skipped)
+func parseJsonTag(field reflect.StructField) (string, bool) {
+	name := field.Name
+	omitEmpty := false
+
+	jsonTag, ok := field.Tag.Lookup("json")
+	if !ok {
+		return name, omitEmpty
+	}
+	if jsonTag == "-" {
+		return "-", omitEmpty
+	}
+
+	parts := strings.Split(jsonTag, ",")
+	if len(parts[0]) > 0 {
+		name = parts[0]
+	}
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" {
+			omitEmpty = true
+		}
+	}
+
+	return name, omitEmpty
+}
+
+// setRule replaces the 'acl' rule of the field
+func (f *fieldInfo) setRule(rule string, source Source) {
+	f.AclRule = rule
+	f.AclTerms = parseAcl(rule)
+	f.AclSource = source
+	if len(rule) == 0 {
+		f.AclSource = ""
+	}
+}
+
+// rule renders the field 'acl' tag as it appears in the source
+func (f fieldInfo) rule() string {
+	if len(f.AclRule) == 0 {
+		return ""
+	}
+	return tagName + ":\"" + f.AclRule + "\""
+}
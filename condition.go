@@ -0,0 +1,156 @@
+// Copyright 2020 Alexander Zherdev. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package acllibgo
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+const (
+	conditionAfter  string = "after"
+	conditionBefore string = "before"
+	conditionFlag   string = "flag"
+)
+
+// aclCondition is a built-in condition used in place of a predicate name, e.g. analyst@after(release_at)
+//   - after(field) : the subject clock is at or past the time in the sibling field
+//   - before(field) : the subject clock is before the time in the sibling field
+//   - flag(name) : the subject flag is set
+//
+// Field is the index of the sibling field, resolved when the plan of the type is compiled, -1 when unknown.
+type aclCondition struct {
+	Name  string
+	Arg   string
+	Field int
+}
+
+// parseCondition parses name(arg), returns false when the text is a plain predicate name
+func parseCondition(text string) (aclCondition, bool) {
+	open := strings.IndexByte(text, '(')
+	if open < 0 || !strings.HasSuffix(text, ")") {
+		return aclCondition{}, false
+	}
+
+	return aclCondition{
+		Name:  strings.ToLower(strings.TrimSpace(text[:open])),
+		Arg:   strings.TrimSpace(text[open+1 : len(text)-1]),
+		Field: -1,
+	}, true
+}
+
+// resolveConditions resolves the sibling fields the conditions of the struct fields refer to
+// Terms are shared with the type cache so the ones carrying a condition are copied before resolving.
+func resolveConditions(t typeInfo) {
+	for x := range t.Field {
+		f := &t.Field[x]
+		copied := false
+		for y, term := range f.AclTerms {
+			if term.Condition == nil || term.Condition.Name == conditionFlag {
+				continue
+			}
+			if !copied {
+				f.AclTerms = append([]aclTerm(nil), f.AclTerms...)
+				copied = true
+			}
+			condition := *term.Condition
+			condition.Field = siblingField(t, condition.Arg)
+			f.AclTerms[y].Condition = &condition
+		}
+	}
+}
+
+// siblingField finds the field by json name or Go name, ignoring case and underscores
+func siblingField(t typeInfo, name string) int {
+	key := conditionKey(name)
+	for x, f := range t.Field {
+		if f.Exported && (conditionKey(f.JsonName) == key || conditionKey(f.Name) == key) {
+			return x
+		}
+	}
+	return -1
+}
+
+// checkConditions reports the conditions of the terms naming an unknown condition, or a sibling field of the
+// struct type that does not exist or does not hold a time. Such conditions never hold, denying the field to everyone.
+func checkConditions(t typeInfo, terms []aclTerm) []string {
+	rv := []string{}
+	for _, term := range terms {
+		c := term.Condition
+		if c == nil {
+			continue
+		}
+
+		switch c.Name {
+		case conditionFlag:
+			if len(c.Arg) == 0 {
+				rv = append(rv, "missing flag name in "+term.Predicate)
+			}
+		case conditionAfter, conditionBefore:
+			x := siblingField(t, c.Arg)
+			if x < 0 {
+				rv = append(rv, "unknown field "+c.Arg+" in "+term.Predicate)
+				continue
+			}
+			if ft := t.Field[x].Type; ft != timeType && !(ft.Kind() == reflect.Ptr && ft.Elem() == timeType) {
+				rv = append(rv, "field "+c.Arg+" in "+term.Predicate+" is not a time.Time")
+			}
+		default:
+			rv = append(rv, "unknown condition "+term.Predicate)
+		}
+	}
+	return rv
+}
+
+func conditionKey(name string) string {
+	return strings.ToLower(strings.Replace(name, "_", "", -1))
+}
+
+// condition evaluates the condition against the struct value obj and the subject clock and flags
+func (eval *evaluation) condition(t *typeInfo, c *aclCondition, obj reflect.Value) bool {
+	switch c.Name {
+	case conditionFlag:
+		return eval.subject.Flags[c.Arg]
+	case conditionAfter, conditionBefore:
+		if !obj.IsValid() || c.Field < 0 {
+			return false
+		}
+		at, ok := timeValue(t.value(obj, c.Field))
+		if !ok {
+			return false
+		}
+		if c.Name == conditionAfter {
+			return !eval.subject.now().Before(at)
+		}
+		return eval.subject.now().Before(at)
+	}
+
+	return false
+}
+
+// timeValue returns the time held by a time.Time or *time.Time field, false when it is unset
+func timeValue(v reflect.Value) (time.Time, bool) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return time.Time{}, false
+		}
+		v = v.Elem()
+	}
+	if v.Type() != timeType || !v.CanInterface() {
+		return time.Time{}, false
+	}
+
+	at := v.Interface().(time.Time)
+	return at, !at.IsZero()
+}
+
+// now returns the time of the subject clock
+func (s *Subject) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,130 @@
+// Copyright 2020 Alexander Zherdev. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package acllibgo
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type Earnings struct {
+	ReleaseAt time.Time  `json:"release_at"`
+	ClosesAt  *time.Time `json:"closes_at"`
+	Revenue   int        `acl:"analyst@after(release_at)|admin"`
+	Forecast  int        `acl:"analyst@before(ClosesAt)"`
+	Customer  string     `acl:"@flag(support_session)"`
+	Broken    int        `acl:"analyst@after(missing)"`
+}
+
+var releaseAt = time.Date(2020, 4, 1, 16, 0, 0, 0, time.UTC)
+
+func newEarnings() *Earnings {
+	closesAt := releaseAt.Add(24 * time.Hour)
+	return &Earnings{ReleaseAt: releaseAt, ClosesAt: &closesAt, Revenue: 100, Forecast: 120, Customer: "acme", Broken: 1}
+}
+
+func clockAt(at time.Time) func() time.Time {
+	return func() time.Time {
+		return at
+	}
+}
+
+func Test_Scrub_After(t *testing.T) {
+
+	item := newEarnings()
+	assert.NoError(t, ScrubSubject(item, Subject{Groups: []string{"analyst"}, Now: clockAt(releaseAt.Add(-time.Minute))}))
+	assert.Equal(t, 0, item.Revenue)
+	assert.Equal(t, 120, item.Forecast)
+
+	item = newEarnings()
+	assert.NoError(t, ScrubSubject(item, Subject{Groups: []string{"analyst"}, Now: clockAt(releaseAt)}))
+	assert.Equal(t, 100, item.Revenue)
+	assert.Equal(t, 120, item.Forecast)
+	assert.Equal(t, 0, item.Broken)
+
+	item = newEarnings()
+	assert.NoError(t, ScrubSubject(item, Subject{Groups: []string{"user"}, Now: clockAt(releaseAt)}))
+	assert.Equal(t, 0, item.Revenue)
+}
+
+func Test_Scrub_Before(t *testing.T) {
+
+	item := newEarnings()
+	assert.NoError(t, ScrubSubject(item, Subject{Groups: []string{"analyst"}, Now: clockAt(releaseAt.Add(48 * time.Hour))}))
+	assert.Equal(t, 100, item.Revenue)
+	assert.Equal(t, 0, item.Forecast)
+
+	item = newEarnings()
+	item.ClosesAt = nil
+	assert.NoError(t, ScrubSubject(item, Subject{Groups: []string{"analyst"}, Now: clockAt(releaseAt)}))
+	assert.Equal(t, 0, item.Forecast)
+}
+
+func Test_Scrub_Flag(t *testing.T) {
+
+	item := newEarnings()
+	assert.NoError(t, ScrubSubject(item, Subject{Flags: map[string]bool{"support_session": true}}))
+	assert.Equal(t, "acme", item.Customer)
+
+	item = newEarnings()
+	assert.NoError(t, ScrubSubject(item, Subject{Flags: map[string]bool{"support_session": false}}))
+	assert.Equal(t, "", item.Customer)
+}
+
+func Test_Explain_Condition(t *testing.T) {
+
+	explanation, err := ExplainSubject(newEarnings(), Subject{Groups: []string{"analyst"}, Now: clockAt(releaseAt)})
+	assert.NoError(t, err)
+	assert.Contains(t, explanation, Decision{Path: "Revenue", Kept: true, Reason: ReasonCondition, Group: "analyst", Predicate: "after(release_at)", Rule: `acl:"analyst@after(release_at)|admin"`, Source: SourceTag})
+	assert.Contains(t, explanation.String(), "matched condition analyst@after(release_at)")
+}
+
+func Test_ParseCondition(t *testing.T) {
+
+	condition, ok := parseCondition("After( release_at )")
+	assert.True(t, ok)
+	assert.Equal(t, aclCondition{Name: "after", Arg: "release_at", Field: -1}, condition)
+
+	_, ok = parseCondition("sameTenant")
+	assert.False(t, ok)
+}
+
+func Test_CheckGroups_Conditions(t *testing.T) {
+
+	type Release struct {
+		Title     string
+		ReleaseAt time.Time `json:"release_at"`
+		Notes     string    `acl:"analyst@after(relase_at)"`
+		Summary   string    `acl:"analyst@after(Title)|@until(release_at)|@flag()"`
+		Body      string    `acl:"analyst@before(release_at)|@flag(preview)"`
+	}
+
+	e := NewEngine(Options{})
+	e.DeclareGroups("analyst")
+	assert.EqualError(t, e.CheckGroups(reflect.TypeOf(Release{})),
+		"groups: github.com/mralexzee/acllibgo.Release.Notes: unknown field relase_at in after(relase_at); "+
+			"github.com/mralexzee/acllibgo.Release.Summary: field Title in after(Title) is not a time.Time; "+
+			"github.com/mralexzee/acllibgo.Release.Summary: unknown condition until(release_at); "+
+			"github.com/mralexzee/acllibgo.Release.Summary: missing flag name in flag()")
+}
+
+func Test_Policy_Conditions(t *testing.T) {
+
+	p, err := ParsePolicy([]byte(`{"types": {"github.com/mralexzee/acllibgo.Earnings": {
+		"default": "@since(release_at)",
+		"fields": {"Revenue": {"acl": "analyst@after(released)"}, "Forecast": {"acl": "analyst@before(closes_at)"}}}}}`))
+	assert.NoError(t, err)
+	assert.EqualError(t, p.Validate(reflect.TypeOf(Earnings{})),
+		"policy: github.com/mralexzee/acllibgo.Earnings: default: unknown condition since(release_at); "+
+			"github.com/mralexzee/acllibgo.Earnings: field Revenue: unknown field released in after(released)")
+
+	e := NewEngine(Options{})
+	e.RegisterTypes(reflect.TypeOf(Earnings{}))
+	assert.Error(t, e.SetPolicy(p))
+	assert.Equal(t, "", e.PolicyVersion())
+}
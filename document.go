@@ -0,0 +1,488 @@
+// Copyright 2020 Alexander Zherdev. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package acllibgo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// DocumentPolicy maps dotted member paths of untyped documents to rules, e.g. "account.email"
+// Arrays are transparent, "items.price" applies to the members of every element of items, and a '*'
+// segment matches any member. Paths compare case-insensitively and an exact segment takes precedence
+// over '*'. Masks apply to string values, the omit mode removes the member, otherwise a denied member
+// is set to the zero value of its JSON type, null for objects and arrays.
+type DocumentPolicy map[string]FieldPolicy
+
+// ScrubDocument applies the policy to a decoded JSON document in place, see DocumentPolicy
+// The document is a map[string]interface{} or a []interface{}, as produced by json.Unmarshal.
+func ScrubDocument(doc interface{}, groups []string, policy DocumentPolicy) error {
+	return defaultEngine.ScrubDocument(doc, groups, policy)
+}
+
+// ScrubJSON applies the policy to the JSON document and returns the compacted result
+func ScrubJSON(data json.RawMessage, groups []string, policy DocumentPolicy) (json.RawMessage, error) {
+	return defaultEngine.ScrubJSON(data, groups, policy)
+}
+
+// ScrubJSONStream applies the policy to the JSON document read from r while writing it to w
+func ScrubJSONStream(w io.Writer, r io.Reader, groups []string, policy DocumentPolicy) error {
+	return defaultEngine.ScrubJSONStream(w, r, groups, policy)
+}
+
+// KeepDocument sets the members of a decoded JSON document that the selector does not list to their zero
+// value, as Keep does. The zero value is the one of the member JSON type, null for objects and arrays.
+func KeepDocument(doc interface{}, fields []StructField) error {
+	if fields == nil {
+		return errors.New("document: nil fields")
+	}
+	return applyDocument(doc, keepFilter{fields: fields})
+}
+
+// ZeroDocument sets the members of a decoded JSON document that the selector lists to their zero value, as Zero does
+func ZeroDocument(doc interface{}, fields []StructField) error {
+	if fields == nil {
+		return errors.New("document: nil fields")
+	}
+	return applyDocument(doc, zeroFilter{fields: fields})
+}
+
+// KeepJSON sets the members of the JSON document that the selector does not list to their zero value
+func KeepJSON(data json.RawMessage, fields []StructField) (json.RawMessage, error) {
+	if fields == nil {
+		return nil, errors.New("document: nil fields")
+	}
+	return filterRaw(data, keepFilter{fields: fields})
+}
+
+// ZeroJSON sets the members of the JSON document that the selector lists to their zero value
+func ZeroJSON(data json.RawMessage, fields []StructField) (json.RawMessage, error) {
+	if fields == nil {
+		return nil, errors.New("document: nil fields")
+	}
+	return filterRaw(data, zeroFilter{fields: fields})
+}
+
+// ScrubDocument applies the policy to a decoded JSON document in place, see ScrubDocument
+func (e *Engine) ScrubDocument(doc interface{}, groups []string, policy DocumentPolicy) error {
+	f, err := e.newScrubFilter(groups, policy)
+	if err != nil {
+		return err
+	}
+	return applyDocument(doc, f)
+}
+
+// ScrubJSON applies the policy to the JSON document and returns the compacted result
+func (e *Engine) ScrubJSON(data json.RawMessage, groups []string, policy DocumentPolicy) (json.RawMessage, error) {
+	f, err := e.newScrubFilter(groups, policy)
+	if err != nil {
+		return nil, err
+	}
+	return filterRaw(data, f)
+}
+
+// ScrubJSONStream applies the policy to the JSON document read from r while writing it to w
+// Members without a rule below them are copied without being decoded.
+func (e *Engine) ScrubJSONStream(w io.Writer, r io.Reader, groups []string, policy DocumentPolicy) error {
+	f, err := e.newScrubFilter(groups, policy)
+	if err != nil {
+		return err
+	}
+	return filterStream(w, r, f)
+}
+
+type docAction int
+
+const (
+	docKeep docAction = iota
+	docOmit
+	docZero
+	docMask
+)
+
+// documentFilter decides the members of the objects of a document
+// member returns the action for the member key and the filter of its value, nil to keep the value as is.
+// Arrays are transparent, their elements are filtered with the filter of the array.
+type documentFilter interface {
+	member(key string) (docAction, string, documentFilter)
+}
+
+// keepFilter keeps the listed members and zeroes the others, a member listed without nested fields is kept whole
+type keepFilter struct {
+	fields []StructField
+}
+
+func (f keepFilter) member(key string) (docAction, string, documentFilter) {
+	for _, k := range f.fields {
+		if strings.EqualFold(k.Name, key) || k.Name == "*" {
+			if len(k.Fields) == 0 {
+				return docKeep, "", nil
+			}
+			return docKeep, "", keepFilter{fields: k.Fields}
+		}
+	}
+	return docZero, "", nil
+}
+
+// zeroFilter zeroes the listed members, a member listed with nested fields has those zeroed instead
+type zeroFilter struct {
+	fields []StructField
+}
+
+func (f zeroFilter) member(key string) (docAction, string, documentFilter) {
+	var nested []StructField
+	for _, k := range f.fields {
+		if strings.EqualFold(k.Name, key) || k.Name == "*" {
+			if len(k.Fields) == 0 || k.Fields[0].Name == "*" {
+				return docZero, "", nil
+			}
+			nested = k.Fields
+		}
+	}
+	if nested == nil {
+		return docKeep, "", nil
+	}
+	return docKeep, "", zeroFilter{fields: nested}
+}
+
+// docNode is a segment of the compiled document policy
+type docNode struct {
+	field    fieldInfo
+	hasRule  bool
+	children map[string]*docNode
+}
+
+type scrubFilter struct {
+	engine *Engine
+	eval   *evaluation
+	nodes  []*docNode
+}
+
+func (e *Engine) newScrubFilter(groups []string, policy DocumentPolicy) (*scrubFilter, error) {
+	if groups == nil {
+		return nil, errors.New("document: nil acl")
+	}
+
+	eval := e.newEvaluation(&Subject{Groups: groups})
+	root, err := compileDocumentPolicy(policy, eval.state.groups)
+	if err != nil {
+		return nil, err
+	}
+
+	return &scrubFilter{engine: e, eval: eval, nodes: []*docNode{root}}, nil
+}
+
+func compileDocumentPolicy(policy DocumentPolicy, groups *groupRegistry) (*docNode, error) {
+	root := &docNode{}
+	problems := []string{}
+
+	paths := make([]string, 0, len(policy))
+	for path := range policy {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		fp := policy[path]
+		if fp.Mode != "" && fp.Mode != ModeZero && fp.Mode != ModeOmit {
+			problems = append(problems, "unknown mode for path "+path)
+			continue
+		}
+
+		node := root
+		for _, segment := range strings.Split(path, ".") {
+			segment = strings.ToLower(strings.TrimSpace(segment))
+			if len(segment) == 0 {
+				node = nil
+				break
+			}
+			if node.children == nil {
+				node.children = make(map[string]*docNode)
+			}
+			child, ok := node.children[segment]
+			if !ok {
+				child = &docNode{}
+				node.children[segment] = child
+			}
+			node = child
+		}
+		if node == nil {
+			problems = append(problems, "invalid path "+path)
+			continue
+		}
+
+		node.hasRule = len(strings.TrimSpace(fp.Acl)) > 0
+		node.field = fieldInfo{Name: path, Kind: reflect.Interface, Exported: true, Mask: fp.Mask, Omit: fp.Mode == ModeOmit}
+		if node.hasRule {
+			node.field.setRule(strings.TrimSpace(fp.Acl), SourcePolicy)
+			groups.compile(&node.field)
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, errors.New("document: " + strings.Join(problems, "; "))
+	}
+
+	return root, nil
+}
+
+var documentType = typeInfo{Name: "document", Kind: reflect.Map, OwnerField: -1}
+
+func (f *scrubFilter) member(key string) (docAction, string, documentFilter) {
+	lower := strings.ToLower(key)
+
+	var matched []*docNode
+	var rule *docNode
+	for _, wildcard := range []bool{false, true} {
+		for _, node := range f.nodes {
+			child := node.children[lower]
+			if wildcard {
+				child = node.children["*"]
+			}
+			if child == nil {
+				continue
+			}
+			matched = append(matched, child)
+			if rule == nil && child.hasRule {
+				rule = child
+			}
+		}
+	}
+
+	field := fieldInfo{Exported: true}
+	if rule != nil {
+		field = rule.field
+	}
+	if !f.engine.allows(&documentType, &field, f.eval, reflect.Value{}) {
+		switch {
+		case field.Omit:
+			return docOmit, "", nil
+		case len(field.Mask) > 0:
+			return docMask, field.Mask, nil
+		default:
+			return docZero, "", nil
+		}
+	}
+
+	nested := matched[:0]
+	for _, node := range matched {
+		if len(node.children) > 0 {
+			nested = append(nested, node)
+		}
+	}
+	if len(nested) == 0 && !f.engine.options.DenyUntagged {
+		return docKeep, "", nil
+	}
+
+	return docKeep, "", &scrubFilter{engine: f.engine, eval: f.eval, nodes: nested}
+}
+
+// applyDocument filters a decoded document in place
+func applyDocument(doc interface{}, f documentFilter) error {
+	switch doc.(type) {
+	case map[string]interface{}, []interface{}:
+		applyValue(doc, f)
+		return nil
+	}
+	return errors.New("document: expecting map[string]interface{} or []interface{}")
+}
+
+func applyValue(value interface{}, f documentFilter) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, member := range v {
+			action, mask, nested := f.member(key)
+			switch action {
+			case docOmit:
+				delete(v, key)
+			case docZero:
+				v[key] = zeroMember(member)
+			case docMask:
+				if _, ok := member.(string); ok {
+					v[key] = mask
+				} else {
+					v[key] = zeroMember(member)
+				}
+			default:
+				if nested != nil {
+					applyValue(member, nested)
+				}
+			}
+		}
+	case []interface{}:
+		for _, element := range v {
+			applyValue(element, f)
+		}
+	}
+}
+
+// zeroMember returns the zero value of the JSON type of the member, nil for objects and arrays
+func zeroMember(member interface{}) interface{} {
+	if member == nil {
+		return nil
+	}
+	switch reflect.TypeOf(member).Kind() {
+	case reflect.Map, reflect.Slice, reflect.Array, reflect.Ptr, reflect.Interface:
+		return nil
+	}
+	return reflect.Zero(reflect.TypeOf(member)).Interface()
+}
+
+func filterRaw(data json.RawMessage, f documentFilter) (json.RawMessage, error) {
+	buf := new(bytes.Buffer)
+	if err := filterStream(buf, bytes.NewReader(data), f); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// filterStream copies a JSON document from r to w token by token, applying the filter
+// Like json.Unmarshal it accepts a single top-level value, anything but white space after it is an error.
+func filterStream(w io.Writer, r io.Reader, f documentFilter) error {
+	out := bufio.NewWriter(w)
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	if err := filterValue(out, dec, f); err != nil {
+		return err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("document: unexpected data after top-level value")
+	}
+	return out.Flush()
+}
+
+func filterValue(out *bufio.Writer, dec *json.Decoder, f documentFilter) error {
+	if f == nil {
+		return copyValue(out, dec)
+	}
+
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		out.WriteByte('{')
+		first := true
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			key, _ := keyTok.(string)
+
+			action, mask, nested := f.member(key)
+			if action == docOmit {
+				if err := skipValue(dec); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if !first {
+				out.WriteByte(',')
+			}
+			first = false
+			writeToken(out, key)
+			out.WriteByte(':')
+
+			switch action {
+			case docZero, docMask:
+				err = redactValue(out, dec, action, mask)
+			default:
+				err = filterValue(out, dec, nested)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+		out.WriteByte('}')
+	case json.Delim('['):
+		out.WriteByte('[')
+		for x := 0; dec.More(); x++ {
+			if x > 0 {
+				out.WriteByte(',')
+			}
+			if err := filterValue(out, dec, f); err != nil {
+				return err
+			}
+		}
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+		out.WriteByte(']')
+	default:
+		writeToken(out, tok)
+	}
+
+	return nil
+}
+
+// copyValue copies the next value compacted, without decoding it
+func copyValue(out *bufio.Writer, dec *json.Decoder) error {
+	var raw json.RawMessage
+	if err := dec.Decode(&raw); err != nil {
+		return err
+	}
+
+	buf := new(bytes.Buffer)
+	if err := json.Compact(buf, raw); err != nil {
+		return err
+	}
+	out.Write(buf.Bytes())
+	return nil
+}
+
+func skipValue(dec *json.Decoder) error {
+	var raw json.RawMessage
+	return dec.Decode(&raw)
+}
+
+// redactValue replaces the next value with the zero value of its JSON type or with the mask for strings
+func redactValue(out *bufio.Writer, dec *json.Decoder, action docAction, mask string) error {
+	var raw json.RawMessage
+	if err := dec.Decode(&raw); err != nil {
+		return err
+	}
+
+	switch raw[0] {
+	case '"':
+		if action == docMask {
+			writeToken(out, mask)
+		} else {
+			out.WriteString(`""`)
+		}
+	case 't', 'f':
+		out.WriteString("false")
+	case '{', '[', 'n':
+		out.WriteString("null")
+	default:
+		out.WriteString("0")
+	}
+	return nil
+}
+
+func writeToken(out *bufio.Writer, tok json.Token) {
+	switch v := tok.(type) {
+	case json.Number:
+		out.WriteString(v.String())
+	case nil:
+		out.WriteString("null")
+	default:
+		data, _ := json.Marshal(v)
+		out.Write(data)
+	}
+}
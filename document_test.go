@@ -0,0 +1,150 @@
+// Copyright 2020 Alexander Zherdev. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package acllibgo
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const orderDocument = `{
+  "id": 7,
+  "customer": {"name": "John", "email": "john@example.com", "card": "4111", "vip": true},
+  "items": [
+    {"sku": "A1", "price": 10.5, "cost": 4},
+    {"sku": "B2", "price": 3, "cost": 1}
+  ],
+  "notes": {"internal": "call back", "public": "thanks"},
+  "audit": [1, 2]
+}`
+
+var orderPolicy = DocumentPolicy{
+	"customer.email": {Acl: "admin,support"},
+	"customer.card":  {Acl: "admin", Mask: "****"},
+	"customer.vip":   {Acl: "admin"},
+	"items.cost":     {Acl: "admin", Mode: ModeOmit},
+	"notes.*":        {Acl: "support"},
+	"notes.public":   {Acl: "public"},
+	"audit":          {Acl: "admin"},
+}
+
+func decodeOrder(t *testing.T) map[string]interface{} {
+	var doc map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(orderDocument), &doc))
+	return doc
+}
+
+func Test_ScrubJSON(t *testing.T) {
+
+	out, err := ScrubJSON(json.RawMessage(orderDocument), []string{"user"}, orderPolicy)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"id":7,"customer":{"name":"John","email":"","card":"****","vip":false},"items":[{"sku":"A1","price":10.5},{"sku":"B2","price":3}],"notes":{"internal":"","public":"thanks"},"audit":null}`, string(out))
+
+	out, err = ScrubJSON(json.RawMessage(orderDocument), []string{"admin"}, orderPolicy)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"id":7,"customer":{"name":"John","email":"john@example.com","card":"4111","vip":true},"items":[{"sku":"A1","price":10.5,"cost":4},{"sku":"B2","price":3,"cost":1}],"notes":{"internal":"","public":"thanks"},"audit":[1,2]}`, string(out))
+}
+
+func Test_ScrubDocument_SameAsScrubJSON(t *testing.T) {
+
+	for _, groups := range [][]string{{}, {"user"}, {"support"}, {"admin"}} {
+		doc := decodeOrder(t)
+		assert.NoError(t, ScrubDocument(doc, groups, orderPolicy))
+
+		out, err := ScrubJSON(json.RawMessage(orderDocument), groups, orderPolicy)
+		assert.NoError(t, err)
+
+		expected, _ := json.Marshal(doc)
+		assert.JSONEq(t, string(expected), string(out), strings.Join(groups, ","))
+	}
+}
+
+func Test_ScrubJSONStream(t *testing.T) {
+
+	out := new(bytes.Buffer)
+	err := ScrubJSONStream(out, strings.NewReader(`[{"customer":{"card":"4111"}},{"customer":null}]`), []string{}, orderPolicy)
+	assert.NoError(t, err)
+	assert.Equal(t, `[{"customer":{"card":"****"}},{"customer":null}]`, out.String())
+
+	err = ScrubJSONStream(out, strings.NewReader(`{"customer":`), []string{}, orderPolicy)
+	assert.Error(t, err)
+}
+
+func Test_ScrubDocument_DenyUntagged(t *testing.T) {
+
+	e := NewEngine(Options{DenyUntagged: true})
+	out, err := e.ScrubJSON(json.RawMessage(orderDocument), []string{"admin"}, DocumentPolicy{
+		"id":            {Acl: "public"},
+		"customer":      {Acl: "public"},
+		"customer.name": {Acl: "public"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"id":7,"customer":{"name":"John","email":"","card":"","vip":false},"items":null,"notes":null,"audit":null}`, string(out))
+}
+
+func Test_ScrubDocument_Errors(t *testing.T) {
+
+	assert.EqualError(t, ScrubDocument(decodeOrder(t), nil, orderPolicy), "document: nil acl")
+	assert.EqualError(t, ScrubDocument("text", []string{}, orderPolicy), "document: expecting map[string]interface{} or []interface{}")
+	assert.EqualError(t, ScrubDocument(decodeOrder(t), []string{}, DocumentPolicy{"a..b": {Acl: "admin"}, "c": {Mode: "drop"}}),
+		"document: invalid path a..b; unknown mode for path c")
+}
+
+func Test_KeepJSON(t *testing.T) {
+
+	fields, err := Parse("id,customer(name),items(sku)")
+	assert.NoError(t, err)
+
+	out, err := KeepJSON(json.RawMessage(orderDocument), fields)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"id":7,"customer":{"name":"John","email":"","card":"","vip":false},`+
+		`"items":[{"sku":"A1","price":0,"cost":0},{"sku":"B2","price":0,"cost":0}],"notes":null,"audit":null}`, string(out))
+
+	doc := decodeOrder(t)
+	assert.NoError(t, KeepDocument(doc, fields))
+	data, _ := json.Marshal(doc)
+	assert.JSONEq(t, string(out), string(data))
+}
+
+func Test_ZeroJSON(t *testing.T) {
+
+	fields, err := Parse("notes,customer(email,card),items(cost)")
+	assert.NoError(t, err)
+
+	out, err := ZeroJSON(json.RawMessage(orderDocument), fields)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"id":7,"customer":{"name":"John","email":"","card":"","vip":true},`+
+		`"items":[{"sku":"A1","price":10.5,"cost":0},{"sku":"B2","price":3,"cost":0}],"notes":null,"audit":[1,2]}`, string(out))
+
+	doc := decodeOrder(t)
+	assert.NoError(t, ZeroDocument(doc, fields))
+	data, _ := json.Marshal(doc)
+	assert.JSONEq(t, string(out), string(data))
+
+	_, err = ZeroJSON(json.RawMessage(orderDocument), nil)
+	assert.EqualError(t, err, "document: nil fields")
+}
+
+func Test_ScrubJSON_TrailingData(t *testing.T) {
+
+	fields, err := Parse("id")
+	assert.NoError(t, err)
+
+	for _, data := range []string{`{"id":1} {"id":2}`, `{"id":1}]`, `{"id":1} x`, `[1] 2`} {
+		_, err = ScrubJSON(json.RawMessage(data), []string{"admin"}, orderPolicy)
+		assert.Error(t, err, data)
+		_, err = KeepJSON(json.RawMessage(data), fields)
+		assert.Error(t, err, data)
+		assert.Error(t, ScrubJSONStream(new(bytes.Buffer), strings.NewReader(data), []string{"admin"}, orderPolicy), data)
+	}
+
+	out, err := KeepJSON(json.RawMessage(" {\"id\":1}\n\t "), fields)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"id":1}`, string(out))
+}
@@ -0,0 +1,172 @@
+// Copyright 2020 Alexander Zherdev. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package acllibgo
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	aclAny    string = "*"
+	aclPublic string = "public"
+	aclSelf   string = "self"
+
+	aclAuthenticated string = "authenticated"
+	aclAnonymous     string = "anonymous"
+)
+
+// Options configures how an Engine evaluates 'acl' tags
+type Options struct {
+	// DenyUntagged clears fields without an 'acl' tag, fields opt in with acl:"public"
+	// The policy applies at every level of the traversal so a forgotten tag fails closed.
+	DenyUntagged bool
+	// KeywordsAsGroups reads public, self, authenticated and anonymous in 'acl' rules as plain group
+	// names, the meaning they had before they became keywords. Set it when existing tags name groups
+	// that way, acl:"public" is then kept only for callers in the group "public".
+	KeywordsAsGroups bool
+}
+
+// Engine evaluates 'acl' tags according to its options
+// The package level functions use an engine with default options. An Engine is safe for concurrent use.
+type Engine struct {
+	options    Options
+	lock       sync.RWMutex
+	predicates map[string]Predicate
+	types      map[string]reflect.Type
+	state      atomic.Value
+	sequence   uint64
+	groups     *groupRegistry
+}
+
+var defaultEngine = NewEngine(Options{})
+
+// NewEngine creates an engine with the provided options
+func NewEngine(options Options) *Engine {
+	e := &Engine{
+		options:    options,
+		predicates: make(map[string]Predicate),
+		types:      make(map[string]reflect.Type),
+		groups:     newGroupRegistry(options.KeywordsAsGroups),
+	}
+	e.state.Store(newPolicyState(e.groups, nil, nil, "", nil))
+
+	return e
+}
+
+// allows reports whether the subject of the evaluation satisfies the field 'acl' tag
+func (e *Engine) allows(t *typeInfo, f *fieldInfo, eval *evaluation, obj reflect.Value) bool {
+	return e.decide(t, f, eval, obj).Kept
+}
+
+// decide evaluates the field 'acl' tag of the struct value obj for the subject of the evaluation
+// obj may be invalid when there is no object, rules depending on it are then not satisfied.
+// Groups take precedence in order: an exact group, the most specific pattern, then the * keyword.
+func (e *Engine) decide(t *typeInfo, f *fieldInfo, eval *evaluation, obj reflect.Value) decision {
+	if len(f.AclTerms) == 0 {
+		if e.options.DenyUntagged {
+			return decision{Kept: false, Reason: ReasonUntagged}
+		}
+		return decision{Kept: true, Reason: ReasonNoTag}
+	}
+
+	if f.AclKeywords&keywordPublic != 0 {
+		return decision{Kept: true, Reason: ReasonPublic}
+	}
+
+	if eval.groupBits().anyOf(f.AclBits) {
+		return e.decideGroup(f, eval)
+	}
+	if f.AclPatterns || len(eval.patterns) > 0 {
+		if m, ok := eval.matchPatterns(f, eval.named); ok {
+			return decision{Kept: true, Reason: ReasonPattern, Group: m.group, Pattern: m.pattern.Text}
+		}
+	}
+	if f.AclKeywords&keywordAny != 0 && eval.hasGroups() {
+		rv := decision{Kept: true, Reason: ReasonAnyGroup}
+		if len(eval.subject.Groups) > 0 {
+			rv.Group = eval.subject.Groups[0]
+		} else {
+			rv.Group = eval.patterns[0].Text
+		}
+		return rv
+	}
+
+	if f.AclKeywords&keywordAuthenticated != 0 && !eval.subject.Anonymous {
+		return decision{Kept: true, Reason: ReasonAuthenticated}
+	}
+	if f.AclKeywords&keywordAnonymous != 0 && eval.subject.Anonymous {
+		return decision{Kept: true, Reason: ReasonAnonymous}
+	}
+
+	if f.AclKeywords&keywordSelf != 0 && eval.subject.isOwner(t, obj) {
+		return decision{Kept: true, Reason: ReasonSelf}
+	}
+
+	if !f.AclPredicates {
+		return decision{Kept: false, Reason: ReasonNoMatch}
+	}
+	for _, term := range f.AclTerms {
+		if len(term.Predicate) == 0 {
+			continue
+		}
+		if len(term.Group) > 0 && !eval.hasGroup(term) {
+			continue
+		}
+		if term.Condition != nil {
+			if eval.condition(t, term.Condition, obj) {
+				return decision{Kept: true, Reason: ReasonCondition, Group: term.Group, Predicate: term.Predicate}
+			}
+			continue
+		}
+		if eval.predicate(e, term.Predicate, obj) {
+			return decision{Kept: true, Reason: ReasonPredicate, Group: term.Group, Predicate: term.Predicate}
+		}
+	}
+
+	return decision{Kept: false, Reason: ReasonNoMatch}
+}
+
+// decideGroup keeps a field whose group terms intersect the subject groups
+// The matched group is named for explanations only, it is the first of the subject groups in the field 'acl' tag.
+func (e *Engine) decideGroup(f *fieldInfo, eval *evaluation) decision {
+	rv := decision{Kept: true, Reason: ReasonGroup}
+	if !eval.named {
+		return rv
+	}
+
+	for _, g := range eval.subject.Groups {
+		if index, ok := eval.state.groups.lookup(g); ok && f.AclBits.has(index) {
+			rv.Group = g
+			break
+		}
+	}
+
+	return rv
+}
+
+// RegisterPredicate registers a named predicate with the default engine, see Engine.RegisterPredicate
+func RegisterPredicate(name string, predicate Predicate) {
+	defaultEngine.RegisterPredicate(name, predicate)
+}
+
+// RegisterPredicate makes the predicate available to 'acl' tags as @name
+// A tag term @name keeps the field when the predicate returns true for the struct value, group@name
+// additionally requires the subject to be in the group. Tags referring to an unknown predicate deny.
+func (e *Engine) RegisterPredicate(name string, predicate Predicate) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.predicates[name] = predicate
+}
+
+func (e *Engine) getPredicate(name string) (Predicate, bool) {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	p, ok := e.predicates[name]
+	return p, ok
+}
@@ -0,0 +1,212 @@
+// Copyright 2020 Alexander Zherdev. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package acllibgo
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type Account struct {
+	ID       int64    `json:"id" acl:"public"`
+	Username string   `json:"username" acl:"public"`
+	Email    string   `json:"email" acl:"admin,support"`
+	Password string   `json:"password"`
+	Manager  *Account `json:"manager" acl:"public"`
+}
+
+func newAccount() Account {
+	return Account{
+		ID:       1,
+		Username: "jdoe",
+		Email:    "jdoe@example.com",
+		Password: "hunter2",
+		Manager: &Account{
+			ID:       2,
+			Username: "boss",
+			Email:    "boss@example.com",
+			Password: "letmein",
+		},
+	}
+}
+
+func Test_Engine_DenyUntagged(t *testing.T) {
+
+	e := NewEngine(Options{DenyUntagged: true})
+	testItem := newAccount()
+
+	err := e.Scrub(&testItem, []string{"support"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), testItem.ID)
+	assert.Equal(t, "jdoe", testItem.Username)
+	assert.Equal(t, "jdoe@example.com", testItem.Email)
+	assert.Equal(t, "", testItem.Password)
+
+	// enforced on nested structs
+	assert.Equal(t, "boss", testItem.Manager.Username)
+	assert.Equal(t, "", testItem.Manager.Password)
+}
+
+type secretHolder struct {
+	Name   string `acl:"public"`
+	Secret string
+}
+
+type structValues struct {
+	ID     string `acl:"public"`
+	Inner  secretHolder
+	Tagged secretHolder `acl:"admin"`
+}
+
+func Test_Engine_DenyUntagged_StructValues(t *testing.T) {
+
+	e := NewEngine(Options{DenyUntagged: true})
+	newItem := func() structValues {
+		return structValues{ID: "1", Inner: secretHolder{Name: "a", Secret: "s1"}, Tagged: secretHolder{Name: "b", Secret: "s2"}}
+	}
+
+	// a denied struct value is cleared as a whole, none of its fields survive
+	testItem := newItem()
+	assert.NoError(t, e.Scrub(&testItem, []string{"user"}))
+	assert.Equal(t, structValues{ID: "1"}, testItem)
+
+	testItem = newItem()
+	assert.NoError(t, e.Scrub(&testItem, []string{"admin"}))
+	assert.Equal(t, structValues{ID: "1", Tagged: secretHolder{Name: "b", Secret: "s2"}}, testItem)
+}
+
+func Test_Engine_DenyUntagged_Explain(t *testing.T) {
+
+	e := NewEngine(Options{DenyUntagged: true})
+	testItem := newAccount()
+
+	explanation, err := e.Explain(&testItem, []string{})
+	assert.NoError(t, err)
+	assert.Contains(t, explanation, Decision{Path: "Password", Kept: false, Action: ActionZero, Reason: ReasonUntagged})
+	assert.Contains(t, explanation, Decision{Path: "Manager.ID", Kept: true, Reason: ReasonPublic, Rule: `acl:"public"`, Source: SourceTag})
+	assert.Contains(t, explanation, Decision{Path: "Manager.Email", Kept: false, Action: ActionZero, Reason: ReasonNoMatch, Rule: `acl:"admin,support"`, Source: SourceTag})
+
+	fields := e.VisibleFields(reflect.TypeOf(testItem), []string{})
+	assert.Equal(t, []StructField{{Name: "ID"}, {Name: "Username"}, {Name: "Manager"}}, fields)
+}
+
+func Test_Engine_Default(t *testing.T) {
+
+	testItem := newAccount()
+
+	// untagged fields are not altered, public fields are kept without groups
+	err := Scrub(&testItem, []string{})
+	assert.NoError(t, err)
+	assert.Equal(t, "hunter2", testItem.Password)
+	assert.Equal(t, "jdoe", testItem.Username)
+	assert.Equal(t, "", testItem.Email)
+}
+
+type AdminSettings struct {
+	_        struct{} `acl:"admin"`
+	Name     string   `acl:"public"`
+	Quota    int
+	Secret   string
+	Operator string `acl:"operator"`
+}
+
+func Test_Scrub_StructDefault(t *testing.T) {
+
+	testItem := AdminSettings{Name: "settings", Quota: 10, Secret: "key", Operator: "ops"}
+	err := Scrub(&testItem, []string{"user"})
+	assert.NoError(t, err)
+	assert.Equal(t, AdminSettings{Name: "settings"}, testItem)
+
+	testItem = AdminSettings{Name: "settings", Quota: 10, Secret: "key", Operator: "ops"}
+	err = Scrub(&testItem, []string{"admin"})
+	assert.NoError(t, err)
+	assert.Equal(t, AdminSettings{Name: "settings", Quota: 10, Secret: "key"}, testItem)
+
+	testItem = AdminSettings{Name: "settings", Quota: 10, Secret: "key", Operator: "ops"}
+	err = Scrub(&testItem, []string{"operator"})
+	assert.NoError(t, err)
+	assert.Equal(t, AdminSettings{Name: "settings", Operator: "ops"}, testItem)
+}
+
+func Test_Explain_StructDefault(t *testing.T) {
+
+	testItem := AdminSettings{}
+	explanation, err := Explain(&testItem, []string{"admin"})
+	assert.NoError(t, err)
+	assert.Equal(t, Explanation{
+		{Path: "Name", Kept: true, Reason: ReasonPublic, Rule: `acl:"public"`, Source: SourceTag},
+		{Path: "Quota", Kept: true, Reason: ReasonGroup, Group: "admin", Rule: `acl:"admin"`, Source: SourceDefault},
+		{Path: "Secret", Kept: true, Reason: ReasonGroup, Group: "admin", Rule: `acl:"admin"`, Source: SourceDefault},
+		{Path: "Operator", Kept: false, Action: ActionZero, Reason: ReasonNoMatch, Rule: `acl:"operator"`, Source: SourceTag},
+	}, explanation)
+	assert.Contains(t, explanation.String(), `acl:"admin" (struct default)`)
+
+	info := getTypeInfo(reflect.TypeOf(testItem))
+	assert.Equal(t, "admin", info.DefaultAcl)
+}
+
+func Test_Engine_DenyUntagged_StructDefault(t *testing.T) {
+
+	type Public struct {
+		_    struct{} `acl:"public"`
+		Name string
+	}
+
+	testItem := Public{Name: "open"}
+	err := NewEngine(Options{DenyUntagged: true}).Scrub(&testItem, []string{})
+	assert.NoError(t, err)
+	assert.Equal(t, "open", testItem.Name)
+}
+
+func Test_Engine_KeywordsAsGroups(t *testing.T) {
+
+	type Legacy struct {
+		Notice string `acl:"public"`
+		Token  string `acl:"authenticated,admin"`
+		Guest  string `acl:"Anonymous"`
+		Owner  string `acl:"self"`
+		Any    string `acl:"*"`
+	}
+	newLegacy := func() *Legacy {
+		return &Legacy{Notice: "n", Token: "t", Guest: "g", Owner: "o", Any: "a"}
+	}
+
+	// by default the words are keywords, acl:"public" is kept for everyone
+	item := newLegacy()
+	assert.NoError(t, NewEngine(Options{}).ScrubAnonymous(item))
+	assert.Equal(t, &Legacy{Notice: "n", Guest: "g"}, item)
+
+	// with the option they are the group names they were before keywords existed
+	e := NewEngine(Options{KeywordsAsGroups: true})
+
+	item = newLegacy()
+	assert.NoError(t, e.ScrubAnonymous(item))
+	assert.Equal(t, &Legacy{}, item)
+
+	item = newLegacy()
+	assert.NoError(t, e.Scrub(item, []string{"user"}))
+	assert.Equal(t, &Legacy{Any: "a"}, item)
+
+	item = newLegacy()
+	assert.NoError(t, e.Scrub(item, []string{"PUBLIC", "anonymous", "self"}))
+	assert.Equal(t, &Legacy{Notice: "n", Guest: "g", Owner: "o", Any: "a"}, item)
+
+	item = newLegacy()
+	assert.NoError(t, e.Scrub(item, []string{"authenticated"}))
+	assert.Equal(t, &Legacy{Token: "t", Any: "a"}, item)
+
+	explanation, err := e.Explain(newLegacy(), []string{"public"})
+	assert.NoError(t, err)
+	assert.Contains(t, explanation, Decision{Path: "Notice", Kept: true, Reason: ReasonGroup, Group: "public", Rule: `acl:"public"`, Source: SourceTag})
+
+	e.DeclareGroups("admin")
+	assert.EqualError(t, e.CheckGroups(reflect.TypeOf(Legacy{})),
+		"groups: github.com/mralexzee/acllibgo.Legacy.Notice: unknown group public; "+
+			"github.com/mralexzee/acllibgo.Legacy.Token: unknown group authenticated; "+
+			"github.com/mralexzee/acllibgo.Legacy.Guest: unknown group Anonymous; "+
+			"github.com/mralexzee/acllibgo.Legacy.Owner: unknown group self")
+}
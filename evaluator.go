@@ -0,0 +1,120 @@
+// Copyright 2020 Alexander Zherdev. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package acllibgo
+
+import (
+	"reflect"
+)
+
+// Evaluator evaluates the 'acl' rules of an engine for a single subject without altering anything
+// It serves traversals implemented outside the package, such as encoders, the same way a Scrub call
+// does: the policy version is captured once and predicate results are cached per object.
+// An Evaluator is not safe for concurrent use.
+type Evaluator struct {
+	engine *Engine
+	eval   *evaluation
+}
+
+// FieldPlan describes a struct field with the engine policy applied
+// Index identifies the field for the Evaluator methods. It is the struct field index, except for
+// adapted types where it is the position in the fields the adapter lists, see Evaluator.Adapted.
+type FieldPlan struct {
+	Name      string
+	Index     int
+	Type      reflect.Type
+	Exported  bool
+	Anonymous bool
+	JsonName  string
+	OmitEmpty bool
+	Rule      string
+	Mask      string
+	Omit      bool
+}
+
+// NewEvaluator creates an evaluator for the subject on the default engine
+func NewEvaluator(subject Subject) *Evaluator {
+	return defaultEngine.NewEvaluator(subject)
+}
+
+// NewEvaluator creates an evaluator for the subject
+func (e *Engine) NewEvaluator(subject Subject) *Evaluator {
+	return &Evaluator{engine: e, eval: e.newEvaluation(&subject)}
+}
+
+// Fields returns the fields of the struct type, nil for other types
+func (ev *Evaluator) Fields(t reflect.Type) []FieldPlan {
+	info := ev.eval.typeInfo(t)
+	if info.Kind != reflect.Struct && info.adapter == nil {
+		return nil
+	}
+
+	rv := make([]FieldPlan, len(info.Field))
+	for x, f := range info.Field {
+		rv[x] = FieldPlan{
+			Name:      f.Name,
+			Index:     x,
+			Type:      f.Type,
+			Exported:  f.Exported,
+			Anonymous: f.Anonymous,
+			JsonName:  f.JsonName,
+			OmitEmpty: f.OmitEmpty,
+			Rule:      f.AclRule,
+			Mask:      f.Mask,
+			Omit:      f.Omit,
+		}
+	}
+
+	return rv
+}
+
+// Kept reports whether Scrub keeps the field at index i of the struct value obj
+// Unexported fields are always kept since Scrub never alters them. obj may be the zero Value when there
+// is no object, rules depending on it are then not satisfied.
+func (ev *Evaluator) Kept(t reflect.Type, obj reflect.Value, i int) bool {
+	info := ev.eval.typeInfo(t)
+	f := info.Field[i]
+	return !f.Exported || ev.engine.allows(&info, &f, ev.eval, obj)
+}
+
+// Adapted reports whether an adapter walks the type, its fields are then not struct fields
+// Read and write them with Value, SetValue and Redact, reflect.Value.Field does not find them.
+func (ev *Evaluator) Adapted(t reflect.Type) bool {
+	return ev.eval.typeInfo(t).adapter != nil
+}
+
+// Value returns the field at index i of the struct value obj, read through the adapter of the type if any
+func (ev *Evaluator) Value(t reflect.Type, obj reflect.Value, i int) reflect.Value {
+	info := ev.eval.typeInfo(t)
+	return info.value(obj, i)
+}
+
+// SetValue stores value in the field at index i of the addressable struct value obj, written through the
+// adapter of the type if any
+func (ev *Evaluator) SetValue(t reflect.Type, obj reflect.Value, i int, value reflect.Value) {
+	info := ev.eval.typeInfo(t)
+	info.set(obj, i, value)
+}
+
+// Redact does to the field at index i of the addressable struct value obj what Scrub does to a denied
+// field: it sets the mask of a string field, or clears the field, through the adapter of the type if any
+func (ev *Evaluator) Redact(t reflect.Type, obj reflect.Value, i int) {
+	info := ev.eval.typeInfo(t)
+	f := info.Field[i]
+	if len(f.Mask) == 0 || !info.mask(obj, i, f.Mask) {
+		info.clear(obj, i)
+	}
+}
+
+// Redacted returns the value Scrub leaves in the denied field at index i of the struct value obj
+// That is the field mask of a string field, the zero value otherwise.
+func (ev *Evaluator) Redacted(t reflect.Type, obj reflect.Value, i int) reflect.Value {
+	info := ev.eval.typeInfo(t)
+	f := info.Field[i]
+	rv := reflect.New(f.Type).Elem()
+	if len(f.Mask) > 0 && f.Kind == reflect.String {
+		rv.SetString(f.Mask)
+	}
+	return rv
+}
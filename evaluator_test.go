@@ -0,0 +1,43 @@
+// Copyright 2020 Alexander Zherdev. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package acllibgo
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Evaluator(t *testing.T) {
+
+	person := newPerson()
+	original := person
+	v := reflect.ValueOf(&person).Elem()
+	ev := NewEvaluator(Subject{Groups: []string{"tester"}})
+
+	fields := ev.Fields(v.Type())
+	assert.Equal(t, "Height", fields[1].Name)
+	assert.Equal(t, "height", fields[1].JsonName)
+	assert.Equal(t, "tester", fields[1].Rule)
+	assert.Nil(t, ev.Fields(reflect.TypeOf(1)))
+
+	assert.True(t, ev.Kept(v.Type(), v, 1))
+	assert.False(t, ev.Kept(v.Type(), v, 2))
+	assert.Equal(t, original, person)
+}
+
+func Test_Evaluator_Redacted(t *testing.T) {
+
+	person := newPerson()
+	original := person
+	v := reflect.ValueOf(&person).Elem()
+	ev := NewEvaluator(Subject{})
+
+	assert.Equal(t, int32(0), ev.Redacted(v.Type(), v, 1).Interface())
+	assert.Nil(t, ev.Redacted(v.Type(), v, 2).Interface())
+	assert.Equal(t, Cat{}, ev.Redacted(v.Type(), v, 8).Interface())
+	assert.Equal(t, original, person)
+}
@@ -0,0 +1,243 @@
+// Copyright 2020 Alexander Zherdev. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package acllibgo
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"text/tabwriter"
+)
+
+// Reason explains why Scrub keeps or clears a field
+type Reason string
+
+const (
+	// ReasonNoTag the field has no 'acl' tag and is kept
+	ReasonNoTag Reason = "no tag"
+	// ReasonUntagged the field has no 'acl' tag and the engine denies untagged fields
+	ReasonUntagged Reason = "untagged"
+	// ReasonPublic the field is tagged acl:"public"
+	ReasonPublic Reason = "public"
+	// ReasonAnyGroup the field is tagged acl:"*" and at least one group was provided
+	ReasonAnyGroup Reason = "matched *"
+	// ReasonGroup one of the provided groups is listed in the field 'acl' tag
+	ReasonGroup Reason = "matched group"
+	// ReasonPattern a provided group matches a pattern of the field 'acl' tag, or a provided pattern matches a group of it
+	ReasonPattern Reason = "matched pattern"
+	// ReasonAuthenticated the field is tagged 'authenticated' and the subject is not anonymous
+	ReasonAuthenticated Reason = "matched authenticated"
+	// ReasonAnonymous the field is tagged 'anonymous' and the subject is anonymous
+	ReasonAnonymous Reason = "matched anonymous"
+	// ReasonSelf the field is tagged 'self' and the struct owner is the subject
+	ReasonSelf Reason = "matched self"
+	// ReasonPredicate a predicate referenced by the field 'acl' tag returned true
+	ReasonPredicate Reason = "matched predicate"
+	// ReasonCondition a condition of the field 'acl' tag, such as after(field) or flag(name), holds
+	ReasonCondition Reason = "matched condition"
+	// ReasonNoMatch none of the provided groups is listed in the field 'acl' tag
+	ReasonNoMatch Reason = "no group match"
+)
+
+// Source tells where the 'acl' rule of a field comes from
+type Source string
+
+const (
+	// SourceTag the rule is the field's own 'acl' tag
+	SourceTag Source = "tag"
+	// SourceDefault the rule is inherited from the struct default, the 'acl' tag of the blank _ field
+	SourceDefault Source = "default"
+	// SourcePolicy the rule comes from a Policy set on the engine
+	SourcePolicy Source = "policy"
+	// SourceAdapter the rule comes from an Adapter registered on the engine
+	SourceAdapter Source = "adapter"
+)
+
+type decision struct {
+	Kept      bool
+	Reason    Reason
+	Group     string
+	Pattern   string
+	Predicate string
+}
+
+// Decision is the outcome of the ACL evaluation of a single field
+// Action is the redaction Scrub applies to a field it does not keep, the same ScrubWithReport reports.
+type Decision struct {
+	Path      string `json:"path"`
+	Kept      bool   `json:"kept"`
+	Action    Action `json:"action,omitempty"`
+	Reason    Reason `json:"reason"`
+	Group     string `json:"group,omitempty"`
+	Pattern   string `json:"pattern,omitempty"`
+	Predicate string `json:"predicate,omitempty"`
+	Rule      string `json:"rule,omitempty"`
+	Source    Source `json:"source,omitempty"`
+}
+
+// Explanation lists the decisions for every field Scrub visits, in traversal order
+type Explanation []Decision
+
+// String renders the explanation as an aligned text table
+func (e Explanation) String() string {
+	buf := new(bytes.Buffer)
+	w := tabwriter.NewWriter(buf, 0, 4, 2, ' ', 0)
+	for _, d := range e {
+		outcome := "cleared"
+		if d.Kept {
+			outcome = "kept"
+		} else if d.Action == ActionMask {
+			outcome = "masked"
+		}
+
+		reason := string(d.Reason)
+		if d.Reason == ReasonGroup {
+			reason += " " + d.Group
+		}
+		if d.Reason == ReasonPattern {
+			reason += " " + d.Pattern + " with " + d.Group
+		}
+		if d.Reason == ReasonPredicate || d.Reason == ReasonCondition {
+			reason += " " + d.Group + "@" + d.Predicate
+		}
+
+		rule := d.Rule
+		if d.Source == SourceDefault {
+			rule += " (struct default)"
+		}
+
+		w.Write([]byte(d.Path + "\t" + outcome + "\t" + reason + "\t" + rule + "\n"))
+	}
+	w.Flush()
+
+	return buf.String()
+}
+
+// Explain performs the same traversal as Scrub without altering the item
+// Returns the decision for every field Scrub would visit and the reason for it, fields of cleared
+// structs are not visited. The result is printable as text and serializable with encoding/json.
+func Explain(item interface{}, acl []string) (Explanation, error) {
+	return defaultEngine.Explain(item, acl)
+}
+
+// ExplainSubject performs the same traversal as ScrubSubject without altering the item
+func ExplainSubject(item interface{}, subject Subject) (Explanation, error) {
+	return defaultEngine.ExplainSubject(item, subject)
+}
+
+// Explain performs the same traversal as Engine.Scrub without altering the item
+func (e *Engine) Explain(item interface{}, acl []string) (Explanation, error) {
+	if acl == nil {
+		return Explanation{}, errors.New("explain: nil acl")
+	}
+	return e.ExplainSubject(item, Subject{Groups: acl})
+}
+
+// ExplainSubject performs the same traversal as Engine.ScrubSubject without altering the item
+func (e *Engine) ExplainSubject(item interface{}, subject Subject) (Explanation, error) {
+	rv := Explanation{}
+	eval := e.newEvaluation(&subject)
+	eval.named = true
+	err := e.explain(item, eval, &rv, "")
+	return rv, err
+}
+
+func (e *Engine) explain(item interface{}, eval *evaluation, rv *Explanation, path string) error {
+	if item == nil {
+		return errors.New("explain: nil item")
+	}
+
+	itemValue := reflect.ValueOf(item)
+	if !itemValue.IsValid() {
+		return nil
+	}
+
+	// Supports a pointer to a struct, an array of pointers to struct, and map of pointers to struct
+	switch itemValue.Kind() {
+	case reflect.Slice, reflect.Array:
+		arrItemType := reflect.TypeOf(item).Elem()
+		if arrItemType.Kind() != reflect.Ptr {
+			return errors.New("explain: expecting pointer for slice or array elements")
+		}
+
+		for i := 0; i < itemValue.Len(); i++ {
+			item := itemValue.Index(i)
+			e.explain(item.Interface(), eval, rv, indexPath(true, path, i))
+		}
+
+		return nil
+	case reflect.Map:
+		arrItemType := reflect.TypeOf(item).Elem()
+		if arrItemType.Kind() != reflect.Ptr {
+			return errors.New("explain: expecting pointer for map values")
+		}
+
+		for _, mKey := range itemValue.MapKeys() {
+			mapValue := itemValue.MapIndex(mKey)
+			if mapValue.Kind() == reflect.Ptr && !mapValue.IsNil() {
+				e.explain(mapValue.Interface(), eval, rv, keyPath(true, path, mKey))
+			}
+		}
+
+		return nil
+	case reflect.Ptr:
+		if itemValue.IsNil() {
+			return errors.New("explain: nil " + itemValue.Type().String())
+		}
+
+	default:
+		return errors.New("explain: expecting pointer, slice, or map")
+	}
+
+	elemValue := itemValue.Elem()
+	if !elemValue.IsValid() {
+		return nil
+	}
+
+	elemTypeInfo := eval.typeInfo(elemValue.Type())
+
+	// Ensure we have a struct
+	if elemTypeInfo.Kind != reflect.Struct && elemTypeInfo.adapter == nil {
+		return errors.New("explain: expecting struct, got " + elemTypeInfo.ToStringValue)
+	}
+
+	for i := 0; i < len(elemTypeInfo.Field); i++ {
+		itemFieldInfo := elemTypeInfo.Field[i]
+		if !itemFieldInfo.Exported {
+			continue
+		}
+
+		d := e.decide(&elemTypeInfo, &itemFieldInfo, eval, elemValue)
+		var action Action
+		if !d.Kept {
+			action = ActionZero
+			if len(itemFieldInfo.Mask) > 0 && itemFieldInfo.Kind == reflect.String {
+				action = ActionMask
+			}
+		}
+		*rv = append(*rv, Decision{
+			Path:      fieldPath(true, path, itemFieldInfo.Name),
+			Kept:      d.Kept,
+			Action:    action,
+			Reason:    d.Reason,
+			Group:     d.Group,
+			Pattern:   d.Pattern,
+			Predicate: d.Predicate,
+			Rule:      itemFieldInfo.rule(),
+			Source:    itemFieldInfo.AclSource,
+		})
+
+		// follow the fields Scrub would descend into
+		if d.Kept {
+			switch itemFieldInfo.Kind {
+			case reflect.Ptr, reflect.Array, reflect.Slice, reflect.Map:
+				ev := elemTypeInfo.value(elemValue, i)
+				e.explain(ev.Interface(), eval, rv, fieldPath(true, path, itemFieldInfo.Name))
+			}
+		}
+	}
+
+	return nil
+}
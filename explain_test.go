@@ -0,0 +1,130 @@
+// Copyright 2020 Alexander Zherdev. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package acllibgo
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Explain_Basic(t *testing.T) {
+
+	testItem := newPerson()
+
+	e, err := Explain(&testItem, []string{"user", "TESTER"})
+	assert.NoError(t, err)
+
+	// item is not altered
+	assert.Equal(t, newPerson().Groups, testItem.Groups)
+
+	decisions := map[string]Decision{}
+	for _, d := range e {
+		decisions[d.Path] = d
+	}
+
+	assert.Equal(t, Decision{Path: "Age", Kept: true, Reason: ReasonNoTag}, decisions["Age"])
+	assert.Equal(t, Decision{Path: "Height", Kept: true, Reason: ReasonGroup, Group: "TESTER", Rule: `acl:"tester"`, Source: SourceTag}, decisions["Height"])
+	assert.Equal(t, Decision{Path: "Groups", Kept: false, Action: ActionZero, Reason: ReasonNoMatch, Rule: `acl:"admin"`, Source: SourceTag}, decisions["Groups"])
+	assert.Equal(t, Decision{Path: "Nickname", Kept: true, Reason: ReasonAnyGroup, Group: "user", Rule: `acl:"*"`, Source: SourceTag}, decisions["Nickname"])
+	assert.Equal(t, ReasonNoMatch, decisions["Children[1].Groups"].Reason)
+	assert.Equal(t, ReasonGroup, decisions["Friends[best].Mother"].Reason)
+	assert.Equal(t, ReasonGroup, decisions["Mother.Height"].Reason)
+}
+
+func Test_Explain_NoGroups(t *testing.T) {
+
+	testItem := newPerson()
+
+	e, err := Explain(&testItem, []string{})
+	assert.NoError(t, err)
+
+	for _, d := range e {
+		// cleared structs are not visited
+		assert.False(t, strings.HasPrefix(d.Path, "Mother."))
+		if d.Path == "Nickname" {
+			assert.False(t, d.Kept)
+			assert.Equal(t, ReasonNoMatch, d.Reason)
+		}
+	}
+}
+
+func Test_Explain_Render(t *testing.T) {
+
+	testItem := newPerson()
+
+	e, err := Explain(&testItem, []string{"admin"})
+	assert.NoError(t, err)
+
+	text := e.String()
+	assert.Contains(t, text, "Groups")
+	assert.Contains(t, text, "matched group admin")
+	assert.Contains(t, text, "cleared")
+	assert.Equal(t, len(e), strings.Count(text, "\n"))
+
+	j, err := json.Marshal(e)
+	assert.NoError(t, err)
+	assert.Contains(t, string(j), `{"path":"Groups","kept":true,"reason":"matched group","group":"admin","rule":"acl:\"admin\"","source":"tag"}`)
+}
+
+func Test_Explain_Invalid(t *testing.T) {
+
+	_, err := Explain(nil, []string{})
+	assert.Error(t, err)
+	_, err = Explain(newPerson(), []string{})
+	assert.Error(t, err)
+	_, err = Explain(&Person{}, nil)
+	assert.Error(t, err)
+}
+
+func Test_Explain_Mask(t *testing.T) {
+
+	e := newPolicyEngine(t, genPolicy)
+	p, err := ParsePolicy([]byte(`{"types": {"github.com/mralexzee/acllibgo.GenUser": {"fields": {"Email": {"mask": "***"}}}}}`))
+	assert.NoError(t, err)
+	assert.NoError(t, e.MergePolicy(p))
+
+	testItem := newGenUser()
+	explanation, err := e.Explain(&testItem, []string{"user"})
+	assert.NoError(t, err)
+	report, err := e.ScrubWithReport(&testItem, []string{"user"})
+	assert.NoError(t, err)
+
+	// every field Scrub redacts is explained with the same action
+	actions := map[string]Action{}
+	for _, d := range explanation {
+		actions[d.Path] = d.Action
+	}
+	for _, r := range report {
+		assert.Equal(t, r.Action, actions[r.Path], r.Path)
+	}
+	assert.Equal(t, ActionMask, actions["Email"])
+	assert.Contains(t, explanation.String(), "masked")
+}
+
+func Test_Explain_StructValues(t *testing.T) {
+
+	e := NewEngine(Options{DenyUntagged: true})
+	testItem := structValues{ID: "1", Inner: secretHolder{Name: "a", Secret: "s1"}, Tagged: secretHolder{Name: "b", Secret: "s2"}}
+
+	explanation, err := e.Explain(&testItem, []string{"user"})
+	assert.NoError(t, err)
+	assert.Equal(t, Explanation{
+		{Path: "ID", Kept: true, Reason: ReasonPublic, Rule: `acl:"public"`, Source: SourceTag},
+		{Path: "Inner", Kept: false, Action: ActionZero, Reason: ReasonUntagged},
+		{Path: "Tagged", Kept: false, Action: ActionZero, Reason: ReasonNoMatch, Rule: `acl:"admin"`, Source: SourceTag},
+	}, explanation)
+
+	// the struct values explained and reported as cleared are cleared
+	report, err := e.ScrubWithReport(&testItem, []string{"user"})
+	assert.NoError(t, err)
+	assert.Equal(t, []Redaction{
+		{Path: "Inner", Action: ActionZero},
+		{Path: "Tagged", Rule: `acl:"admin"`, Action: ActionZero},
+	}, report)
+	assert.Equal(t, structValues{ID: "1"}, testItem)
+}
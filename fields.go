@@ -0,0 +1,83 @@
+// Copyright 2020 Alexander Zherdev. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package acllibgo
+
+import (
+	"reflect"
+)
+
+// VisibleFields returns the tree of fields Scrub leaves intact for the provided groups
+// Type may be a struct or a pointer, slice, array, or map of structs. Nested fields are listed
+// wherever Scrub descends (pointer to struct, slice, array or map of pointers to struct), so the
+// result can be passed directly to Keep. A field referring back to a type that is already being
+// expanded is listed without nested fields. Rules depending on the object, such as 'self', are
+// never satisfied since there is no object to evaluate them against.
+func VisibleFields(itemType reflect.Type, groups []string) []StructField {
+	return defaultEngine.VisibleFields(itemType, groups)
+}
+
+// VisibleFields returns the tree of fields Engine.Scrub leaves intact for the provided groups
+func (e *Engine) VisibleFields(itemType reflect.Type, groups []string) []StructField {
+	structType, ok := structElem(itemType)
+	if !ok {
+		return []StructField{}
+	}
+
+	return e.visibleFields(structType, e.newEvaluation(&Subject{Groups: groups}), map[reflect.Type]bool{})
+}
+
+func (e *Engine) visibleFields(structType reflect.Type, eval *evaluation, expanding map[reflect.Type]bool) []StructField {
+	expanding[structType] = true
+	defer delete(expanding, structType)
+
+	typeInfo := eval.typeInfo(structType)
+	rv := make([]StructField, 0, len(typeInfo.Field))
+	for _, f := range typeInfo.Field {
+		if !f.Exported || !e.allows(&typeInfo, &f, eval, reflect.Value{}) {
+			continue
+		}
+
+		delta := StructField{Name: f.Name}
+		if nested, ok := scrubbedElem(f.Type); ok && !expanding[nested] {
+			delta.Fields = e.visibleFields(nested, eval, expanding)
+		}
+
+		rv = append(rv, delta)
+	}
+
+	return rv
+}
+
+// structElem unwraps pointers, slices, arrays, and maps until it reaches a struct type
+func structElem(t reflect.Type) (reflect.Type, bool) {
+	for t != nil {
+		switch t.Kind() {
+		case reflect.Struct:
+			return t, true
+		case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+			t = t.Elem()
+		default:
+			return nil, false
+		}
+	}
+
+	return nil, false
+}
+
+// scrubbedElem returns the struct type Scrub descends into for a field of the given type
+func scrubbedElem(t reflect.Type) (reflect.Type, bool) {
+	switch t.Kind() {
+	case reflect.Ptr:
+		if t.Elem().Kind() == reflect.Struct {
+			return t.Elem(), true
+		}
+	case reflect.Slice, reflect.Array, reflect.Map:
+		if t.Elem().Kind() == reflect.Ptr && t.Elem().Elem().Kind() == reflect.Struct {
+			return t.Elem().Elem(), true
+		}
+	}
+
+	return nil, false
+}
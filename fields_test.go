@@ -0,0 +1,70 @@
+// Copyright 2020 Alexander Zherdev. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package acllibgo
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_VisibleFields_Basic(t *testing.T) {
+
+	fields := VisibleFields(reflect.TypeOf(Person{}), []string{"tester"})
+
+	names := []string{}
+	for _, f := range fields {
+		names = append(names, f.Name)
+	}
+	assert.Equal(t, []string{"Age", "Height", "FullName", "Nickname", "Mother", "Father", "Children", "PetCat", "Friends", "Created", "Birthdate"}, names)
+
+	// recursive type is listed without nested fields
+	assert.Nil(t, fields[4].Fields)
+	assert.Nil(t, fields[7].Fields)
+}
+
+func Test_VisibleFields_NoGroups(t *testing.T) {
+
+	fields := VisibleFields(reflect.TypeOf([]*Person{}), []string{})
+
+	names := []string{}
+	for _, f := range fields {
+		names = append(names, f.Name)
+	}
+	assert.Equal(t, []string{"Age", "Father", "Children", "Friends", "Created"}, names)
+}
+
+func Test_VisibleFields_Nested(t *testing.T) {
+
+	type Account struct {
+		Username string
+		Secret   string `acl:"admin"`
+	}
+	type Session struct {
+		ID      string
+		Account *Account
+	}
+
+	fields := VisibleFields(reflect.TypeOf(&Session{}), []string{"user"})
+	assert.Equal(t, []StructField{
+		{Name: "ID"},
+		{Name: "Account", Fields: []StructField{{Name: "Username"}}},
+	}, fields)
+
+	// Keep with the visible fields matches Scrub
+	kept := Session{ID: "1", Account: &Account{Username: "john", Secret: "s3cret"}}
+	scrubbed := kept
+	scrubbed.Account = &Account{Username: "john", Secret: "s3cret"}
+	assert.NoError(t, Keep(&kept, fields))
+	assert.NoError(t, Scrub(&scrubbed, []string{"user"}))
+	assert.Equal(t, scrubbed, kept)
+}
+
+func Test_VisibleFields_InvalidType(t *testing.T) {
+
+	assert.Equal(t, []StructField{}, VisibleFields(reflect.TypeOf(10), []string{"admin"}))
+	assert.Equal(t, []StructField{}, VisibleFields(nil, []string{"admin"}))
+}
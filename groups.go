@@ -0,0 +1,312 @@
+// Copyright 2020 Alexander Zherdev. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package acllibgo
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Group is the name of an ACL group as listed in 'acl' tags, compared case-insensitively
+type Group string
+
+// GroupSet is a validated set of groups, built by Engine.NewGroupSet
+// Membership is a bitset over the group indices of the engine so matching a field is a single AND.
+type GroupSet struct {
+	registry *groupRegistry
+	bits     bitset
+	groups   []string
+}
+
+// Groups returns the names of the groups in the set
+func (s GroupSet) Groups() []Group {
+	rv := make([]Group, len(s.groups))
+	for x, g := range s.groups {
+		rv[x] = Group(g)
+	}
+	return rv
+}
+
+// Has reports whether the group is in the set
+func (s GroupSet) Has(group Group) bool {
+	if s.registry == nil {
+		return false
+	}
+	index, ok := s.registry.lookup(string(group))
+	return ok && s.bits.has(index)
+}
+
+// Len returns the number of groups in the set
+func (s GroupSet) Len() int {
+	return len(s.groups)
+}
+
+// groupRegistry assigns a bit index to every group name of the 'acl' rules and declared groups
+// Indices are never reused so bitsets compiled earlier stay valid. Declared groups are the ones
+// the application expects, any other group found in rules is reported as unknown. Caller groups are
+// only looked up, so untrusted group names never grow the registry.
+type groupRegistry struct {
+	lock     sync.RWMutex
+	index    map[string]int
+	names    []string
+	declared bitset
+	size     int64
+	// keywordsAsGroups compiles public, self, authenticated and anonymous as group names, see Options
+	keywordsAsGroups bool
+}
+
+func newGroupRegistry(keywordsAsGroups bool) *groupRegistry {
+	return &groupRegistry{index: make(map[string]int), keywordsAsGroups: keywordsAsGroups}
+}
+
+func groupKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+func (r *groupRegistry) lookup(name string) (int, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	index, ok := r.index[groupKey(name)]
+	return index, ok
+}
+
+// intern returns the index of the group, assigning the next free one to a new group
+func (r *groupRegistry) intern(name string) int {
+	if index, ok := r.lookup(name); ok {
+		return index
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	key := groupKey(name)
+	if index, ok := r.index[key]; ok {
+		return index
+	}
+	index := len(r.names)
+	r.index[key] = index
+	r.names = append(r.names, name)
+	atomic.StoreInt64(&r.size, int64(len(r.names)))
+
+	return index
+}
+
+// len returns the number of groups interned so far without locking
+func (r *groupRegistry) len() int64 {
+	return atomic.LoadInt64(&r.size)
+}
+
+func (r *groupRegistry) name(index int) string {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.names[index]
+}
+
+func (r *groupRegistry) declare(name string) {
+	index := r.intern(name)
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.declared.set(index)
+}
+
+func (r *groupRegistry) isDeclared(name string) bool {
+	index, ok := r.lookup(name)
+	if !ok {
+		return false
+	}
+
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.declared.has(index)
+}
+
+// compile maps the group names of the field 'acl' terms to their bit indices
+// The groups of group@name terms are interned too so the subject groups resolve against them.
+func (r *groupRegistry) compile(f *fieldInfo) {
+	if r.keywordsAsGroups {
+		f.AclTerms = keywordsAsGroups(f.AclTerms)
+	}
+
+	f.AclBits = nil
+	f.AclPatterns = false
+	f.AclKeywords = 0
+	f.AclPredicates = false
+	for _, term := range f.AclTerms {
+		f.AclKeywords |= term.Keyword
+		if len(term.Predicate) > 0 {
+			f.AclPredicates = true
+		}
+		if term.isGroup() {
+			f.AclBits.set(r.intern(term.Group))
+		}
+		if term.isRestrictedPredicate() {
+			r.intern(term.Group)
+		}
+		if term.Pattern != nil && len(term.Predicate) == 0 {
+			f.AclPatterns = true
+		}
+	}
+}
+
+// keywordsAsGroups returns the terms with the keywords other than * turned into group names
+// Terms are shared with the type cache so they are copied before any is changed.
+func keywordsAsGroups(terms []aclTerm) []aclTerm {
+	rv := terms
+	copied := false
+	for x, term := range terms {
+		if term.Keyword == 0 || term.Keyword == keywordAny {
+			continue
+		}
+		if !copied {
+			rv = append([]aclTerm(nil), terms...)
+			copied = true
+		}
+		rv[x].Keyword = 0
+	}
+	return rv
+}
+
+// DeclareGroups declares the groups known to the default engine, see Engine.DeclareGroups
+func DeclareGroups(groups ...Group) {
+	defaultEngine.DeclareGroups(groups...)
+}
+
+// NewGroupSet builds a group set for the default engine, see Engine.NewGroupSet
+func NewGroupSet(groups ...Group) (GroupSet, error) {
+	return defaultEngine.NewGroupSet(groups...)
+}
+
+// ScrubGroups scrubs the item like Scrub for a validated group set, see Engine.NewGroupSet
+func ScrubGroups(item interface{}, groups GroupSet) error {
+	return defaultEngine.ScrubGroups(item, groups)
+}
+
+// DeclareGroups declares the groups the application uses
+// Once groups are declared NewGroupSet rejects any other group and CheckGroups reports 'acl' tags
+// and policies referring to groups that were not declared.
+func (e *Engine) DeclareGroups(groups ...Group) {
+	for _, g := range groups {
+		e.groups.declare(string(g))
+	}
+}
+
+// NewGroupSet builds the validated group set of a caller
+// Returns an error naming every group that was not declared with DeclareGroups.
+func (e *Engine) NewGroupSet(groups ...Group) (GroupSet, error) {
+	rv := GroupSet{registry: e.groups, groups: make([]string, 0, len(groups))}
+	unknown := []string{}
+	for _, g := range groups {
+		if !e.groups.isDeclared(string(g)) {
+			unknown = append(unknown, string(g))
+			continue
+		}
+		index, _ := e.groups.lookup(string(g))
+		rv.bits.set(index)
+		rv.groups = append(rv.groups, string(g))
+	}
+
+	if len(unknown) > 0 {
+		return GroupSet{}, errors.New("groups: unknown group " + strings.Join(unknown, ", "))
+	}
+
+	return rv, nil
+}
+
+// ScrubGroups scrubs the item like Engine.Scrub for a validated group set
+func (e *Engine) ScrubGroups(item interface{}, groups GroupSet) error {
+	if groups.registry != e.groups {
+		return errors.New("scrub: group set built by another engine")
+	}
+
+	eval := &evaluation{subject: &Subject{Groups: groups.groups}, state: e.currentState(), groups: groups.bits}
+
+	return e.scrub(item, eval, nil, "")
+}
+
+// CheckGroups reports every group referred to by the 'acl' rules of the types that was not declared
+// The types and the struct types reachable from their fields are checked with the active policy applied.
+// Conditions naming an unknown condition or sibling field are reported too, whether groups are declared or not.
+func (e *Engine) CheckGroups(types ...reflect.Type) error {
+	known := make(map[string]reflect.Type)
+	for _, t := range types {
+		collectTypes(t, known)
+	}
+
+	names := make([]string, 0, len(known))
+	for name := range known {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	state := e.currentState()
+	problems := []string{}
+	for _, name := range names {
+		plan := state.plan(known[name])
+		for _, f := range plan.Field {
+			for _, term := range f.AclTerms {
+				if (term.isGroup() || term.isRestrictedPredicate()) && !e.groups.isDeclared(term.Group) {
+					problems = append(problems, name+"."+f.Name+": unknown group "+term.Group)
+				}
+			}
+			for _, problem := range checkConditions(plan, f.AclTerms) {
+				problems = append(problems, name+"."+f.Name+": "+problem)
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New("groups: " + strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// bitset is a growable set of small non-negative integers
+type bitset []uint64
+
+func (b bitset) has(i int) bool {
+	word := i / 64
+	return word < len(b) && b[word]&(1<<(uint(i)%64)) != 0
+}
+
+func (b *bitset) set(i int) {
+	word := i / 64
+	for len(*b) <= word {
+		*b = append(*b, 0)
+	}
+	(*b)[word] |= 1 << (uint(i) % 64)
+}
+
+func (b bitset) empty() bool {
+	for _, w := range b {
+		if w != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// anyOf reports whether the sets have an index in common
+func (b bitset) anyOf(other bitset) bool {
+	n := len(b)
+	if len(other) < n {
+		n = len(other)
+	}
+	for word := 0; word < n; word++ {
+		if b[word]&other[word] != 0 {
+			return true
+		}
+	}
+	return false
+}
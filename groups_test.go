@@ -0,0 +1,152 @@
+// Copyright 2020 Alexander Zherdev. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package acllibgo
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	groupAdmin   Group = "admin"
+	groupSupport Group = "support"
+)
+
+type Ticket struct {
+	Subject  string
+	Customer string `acl:"support,admin"`
+	Internal string `acl:"admin"`
+	Notes    string `acl:"*"`
+}
+
+func newTicket() *Ticket {
+	return &Ticket{Subject: "Login", Customer: "john@example.com", Internal: "escalated", Notes: "called"}
+}
+
+func newGroupEngine() *Engine {
+	e := NewEngine(Options{})
+	e.DeclareGroups(groupAdmin, groupSupport)
+	return e
+}
+
+func Test_NewGroupSet(t *testing.T) {
+
+	e := newGroupEngine()
+
+	groups, err := e.NewGroupSet("Support")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, groups.Len())
+	assert.True(t, groups.Has(groupSupport))
+	assert.False(t, groups.Has(groupAdmin))
+	assert.Equal(t, []Group{"Support"}, groups.Groups())
+}
+
+func Test_NewGroupSet_Unknown(t *testing.T) {
+
+	e := newGroupEngine()
+
+	_, err := e.NewGroupSet(groupAdmin, "amdin", "suport")
+	assert.EqualError(t, err, "groups: unknown group amdin, suport")
+}
+
+func Test_ScrubGroups(t *testing.T) {
+
+	e := newGroupEngine()
+
+	support, err := e.NewGroupSet(groupSupport)
+	assert.NoError(t, err)
+
+	ticket := newTicket()
+	assert.NoError(t, e.ScrubGroups(ticket, support))
+	assert.Equal(t, "Login", ticket.Subject)
+	assert.Equal(t, "john@example.com", ticket.Customer)
+	assert.Equal(t, "", ticket.Internal)
+	assert.Equal(t, "called", ticket.Notes)
+
+	empty, err := e.NewGroupSet()
+	assert.NoError(t, err)
+
+	ticket = newTicket()
+	assert.NoError(t, e.ScrubGroups(ticket, empty))
+	assert.Equal(t, "Login", ticket.Subject)
+	assert.Equal(t, "", ticket.Customer)
+	assert.Equal(t, "", ticket.Notes)
+}
+
+func Test_ScrubGroups_SameAsScrub(t *testing.T) {
+
+	e := newGroupEngine()
+
+	admin, err := e.NewGroupSet(groupAdmin)
+	assert.NoError(t, err)
+
+	expected := newTicket()
+	assert.NoError(t, e.Scrub(expected, []string{"ADMIN"}))
+
+	ticket := newTicket()
+	assert.NoError(t, e.ScrubGroups(ticket, admin))
+	assert.Equal(t, expected, ticket)
+}
+
+func Test_ScrubGroups_OtherEngine(t *testing.T) {
+
+	admin, err := newGroupEngine().NewGroupSet(groupAdmin)
+	assert.NoError(t, err)
+
+	err = newGroupEngine().ScrubGroups(newTicket(), admin)
+	assert.EqualError(t, err, "scrub: group set built by another engine")
+}
+
+func Test_CheckGroups(t *testing.T) {
+
+	e := NewEngine(Options{})
+	e.DeclareGroups(groupAdmin)
+
+	err := e.CheckGroups(reflect.TypeOf(Ticket{}))
+	assert.EqualError(t, err, "groups: github.com/mralexzee/acllibgo.Ticket.Customer: unknown group support")
+
+	e.DeclareGroups(groupSupport)
+	assert.NoError(t, e.CheckGroups(reflect.TypeOf(&Ticket{})))
+}
+
+func Test_Scrub_ManyGroups(t *testing.T) {
+
+	groups := make([]string, 0, 100)
+	for x := 0; x < 99; x++ {
+		groups = append(groups, "team"+string(rune('a'+x%26))+string(rune('a'+x/26)))
+	}
+	groups = append(groups, "SUPPORT")
+
+	ticket := newTicket()
+	assert.NoError(t, NewEngine(Options{}).Scrub(ticket, groups))
+	assert.Equal(t, "john@example.com", ticket.Customer)
+	assert.Equal(t, "", ticket.Internal)
+	assert.Equal(t, "called", ticket.Notes)
+}
+
+func Test_Scrub_CallerGroupsNotInterned(t *testing.T) {
+
+	e := NewEngine(Options{})
+
+	// the first call compiles the plan, the caller group is resolved once the tag groups are known
+	ticket := newTicket()
+	assert.NoError(t, e.Scrub(ticket, []string{"Support"}))
+	assert.Equal(t, "john@example.com", ticket.Customer)
+	size := e.groups.len()
+
+	for x := 0; x < 1000; x++ {
+		ticket = newTicket()
+		assert.NoError(t, e.Scrub(ticket, []string{"claim" + strconv.Itoa(x), "support"}))
+		assert.Equal(t, "john@example.com", ticket.Customer)
+		assert.Equal(t, "", ticket.Internal)
+	}
+	assert.Equal(t, size, e.groups.len())
+
+	_, found := e.groups.lookup("claim1")
+	assert.False(t, found)
+}
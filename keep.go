@@ -17,6 +17,15 @@ type StructField struct {
 
 // Keep retains the value of the properties provided, other properties are set to defaults
 func Keep(item interface{}, fields []StructField) error {
+	return keep(item, fields, nil, "")
+}
+
+// KeepWithObserver keeps the fields like Keep and reports every cleared field to the observer
+func KeepWithObserver(item interface{}, fields []StructField, observer Observer) error {
+	return keep(item, fields, observer, "")
+}
+
+func keep(item interface{}, fields []StructField, observer Observer, path string) error {
 	if item == nil {
 		return errors.New("fields: nil item")
 	}
@@ -39,7 +48,7 @@ func Keep(item interface{}, fields []StructField) error {
 
 		for i := 0; i < itemValue.Len(); i++ {
 			item := itemValue.Index(i)
-			Keep(item.Interface(), fields)
+			keep(item.Interface(), fields, observer, indexPath(observer != nil, path, i))
 		}
 
 		return nil
@@ -52,7 +61,7 @@ func Keep(item interface{}, fields []StructField) error {
 		for _, mKey := range itemValue.MapKeys() {
 			mapValue := itemValue.MapIndex(mKey)
 			if mapValue.Kind() == reflect.Ptr && !mapValue.IsNil() {
-				Keep(mapValue.Interface(), fields)
+				keep(mapValue.Interface(), fields, observer, keyPath(observer != nil, path, mKey))
 			}
 		}
 
@@ -81,8 +90,10 @@ func Keep(item interface{}, fields []StructField) error {
 	// Identify which properties to clear
 	for i := 0; i < len(elemTypeInfo.Field); i++ {
 		itemFieldInfo := elemTypeInfo.Field[i]
+		if !itemFieldInfo.Exported {
+			continue
+		}
 
-		hasDefaultValue := false
 		found := false
 		fieldFields := []StructField{}
 		for _, k := range fields {
@@ -94,18 +105,23 @@ func Keep(item interface{}, fields []StructField) error {
 		}
 
 		if !found {
-			hasDefaultValue = true
 			ev := elemValue.Field(i)
 			setToDefault(ev)
+			if observer != nil {
+				observer.Redacted(Redaction{
+					Path:   fieldPath(observer != nil, path, itemFieldInfo.Name),
+					Rule:   RuleKeep,
+					Action: ActionZero,
+				})
+			}
+			continue
 		}
 
 		// scrub field if we have not set it to default and it's a supports type
-		if !hasDefaultValue {
-			switch itemFieldInfo.Kind {
-			case reflect.Ptr, reflect.Array, reflect.Slice, reflect.Map:
-				ev := elemValue.Field(i)
-				Keep(ev.Interface(), fieldFields)
-			}
+		switch itemFieldInfo.Kind {
+		case reflect.Ptr, reflect.Array, reflect.Slice, reflect.Map:
+			ev := elemValue.Field(i)
+			keep(ev.Interface(), fieldFields, observer, fieldPath(observer != nil, path, itemFieldInfo.Name))
 		}
 	}
 
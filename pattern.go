@@ -0,0 +1,133 @@
+// Copyright 2020 Alexander Zherdev. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package acllibgo
+
+import (
+	"path"
+	"strings"
+)
+
+// groupSeparator separates the segments of namespaced groups such as org:acme:billing:read
+const groupSeparator string = ":"
+
+// groupPattern is a compiled glob over namespaced group names
+// A '*' segment matches exactly one segment, a '**' segment matches any number of segments, including
+// none, and a segment containing '*' elsewhere matches like path.Match within the segment, e.g. bill*.
+// Segments compare case-insensitively.
+type groupPattern struct {
+	Text     string
+	segments []string
+	literals int
+	globs    int
+}
+
+// isGroupPattern reports whether the group name is a pattern, the bare keyword * is not
+func isGroupPattern(name string) bool {
+	return name != aclAny && strings.Contains(name, "*")
+}
+
+func compileGroupPattern(text string) *groupPattern {
+	rv := &groupPattern{Text: text, segments: strings.Split(strings.ToLower(text), groupSeparator)}
+	for _, segment := range rv.segments {
+		switch {
+		case segment == "**":
+			rv.globs++
+		case !strings.Contains(segment, "*"):
+			rv.literals++
+		}
+	}
+	return rv
+}
+
+// match reports whether the group name matches the pattern
+func (p *groupPattern) match(group string) bool {
+	return matchSegments(p.segments, strings.Split(strings.ToLower(group), groupSeparator))
+}
+
+func matchSegments(pattern, group []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for skip := 0; skip <= len(group); skip++ {
+				if matchSegments(pattern[1:], group[skip:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(group) == 0 || !matchSegment(pattern[0], group[0]) {
+			return false
+		}
+		pattern, group = pattern[1:], group[1:]
+	}
+
+	return len(group) == 0
+}
+
+func matchSegment(pattern, segment string) bool {
+	if pattern == "*" {
+		return true
+	}
+	if !strings.Contains(pattern, "*") {
+		return pattern == segment
+	}
+	ok, err := path.Match(pattern, segment)
+	return ok && err == nil
+}
+
+// moreSpecific reports whether the pattern takes precedence over the other one
+// The pattern with more literal segments wins, then the one with fewer '**' segments, then the longer one.
+func (p *groupPattern) moreSpecific(other *groupPattern) bool {
+	if p.literals != other.literals {
+		return p.literals > other.literals
+	}
+	if p.globs != other.globs {
+		return p.globs < other.globs
+	}
+	return len(p.segments) > len(other.segments)
+}
+
+// patternMatch is a subject group matched through a pattern, either the tag's or the subject's
+type patternMatch struct {
+	group   string
+	pattern *groupPattern
+}
+
+// matchPatterns matches the pattern terms of the field against the subject groups and the group terms
+// of the field against the subject patterns. The most specific match is returned when all is set,
+// otherwise the first one found. Subject groups are literal names, one containing '*' matches nothing.
+func (eval *evaluation) matchPatterns(f *fieldInfo, all bool) (patternMatch, bool) {
+	var rv patternMatch
+	found := false
+	consider := func(group string, pattern *groupPattern) bool {
+		if !found || pattern.moreSpecific(rv.pattern) {
+			rv = patternMatch{group: group, pattern: pattern}
+			found = true
+		}
+		return !all
+	}
+
+	for _, term := range f.AclTerms {
+		if len(term.Predicate) > 0 {
+			continue
+		}
+		if term.Pattern != nil {
+			for _, g := range eval.subject.Groups {
+				if !isGroupPattern(g) && term.Pattern.match(g) && consider(g, term.Pattern) {
+					return rv, true
+				}
+			}
+			continue
+		}
+		if term.isGroup() {
+			for _, p := range eval.patterns {
+				if p.match(term.Group) && consider(term.Group, p) {
+					return rv, true
+				}
+			}
+		}
+	}
+
+	return rv, found
+}
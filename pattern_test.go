@@ -0,0 +1,134 @@
+// Copyright 2020 Alexander Zherdev. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package acllibgo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type Statement struct {
+	Period  string
+	Total   int    `acl:"org:*:billing:*"`
+	Cards   string `acl:"org:acme:billing:admin"`
+	Ledger  string `acl:"org:**:audit"`
+	Summary string `acl:"org:acme:billing:read,org:*:billing:*,*"`
+}
+
+func newStatement() *Statement {
+	return &Statement{Period: "2020-01", Total: 100, Cards: "4111", Ledger: "entries", Summary: "ok"}
+}
+
+func Test_GroupPattern_Match(t *testing.T) {
+
+	tests := []struct {
+		pattern string
+		group   string
+		match   bool
+	}{
+		{"org:*:billing:*", "org:acme:billing:read", true},
+		{"org:*:billing:*", "ORG:Acme:Billing:Write", true},
+		{"org:*:billing:*", "org:acme:billing", false},
+		{"org:*:billing:*", "org:acme:eu:billing:read", false},
+		{"org:**", "org", true},
+		{"org:**", "org:acme:billing:read", true},
+		{"org:**:audit", "org:audit", true},
+		{"org:**:audit", "org:acme:eu:audit", true},
+		{"org:**:audit", "org:acme:eu:audit:read", false},
+		{"org:acme:bill*", "org:acme:billing", true},
+		{"org:acme:bill*", "org:acme:support", false},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.match, compileGroupPattern(test.pattern).match(test.group), test.pattern+" "+test.group)
+	}
+}
+
+func Test_GroupPattern_Specificity(t *testing.T) {
+
+	assert.True(t, compileGroupPattern("org:acme:*").moreSpecific(compileGroupPattern("org:*:*")))
+	assert.True(t, compileGroupPattern("org:*:*").moreSpecific(compileGroupPattern("org:**")))
+	assert.False(t, compileGroupPattern("org:**").moreSpecific(compileGroupPattern("org:*")))
+}
+
+func Test_Scrub_TagPattern(t *testing.T) {
+
+	item := newStatement()
+	assert.NoError(t, Scrub(item, []string{"org:acme:billing:read"}))
+	assert.Equal(t, "2020-01", item.Period)
+	assert.Equal(t, 100, item.Total)
+	assert.Equal(t, "", item.Cards)
+	assert.Equal(t, "", item.Ledger)
+	assert.Equal(t, "ok", item.Summary)
+
+	item = newStatement()
+	assert.NoError(t, Scrub(item, []string{"org:acme:support:read"}))
+	assert.Equal(t, 0, item.Total)
+	assert.Equal(t, "", item.Cards)
+}
+
+func Test_Scrub_ProvidedPattern(t *testing.T) {
+
+	item := newStatement()
+	assert.NoError(t, ScrubSubject(item, Subject{Patterns: []string{"org:acme:**"}}))
+	assert.Equal(t, 0, item.Total)
+	assert.Equal(t, "4111", item.Cards)
+	assert.Equal(t, "", item.Ledger)
+	assert.Equal(t, "ok", item.Summary)
+}
+
+func Test_Scrub_GroupsAreLiteral(t *testing.T) {
+
+	type Account struct {
+		Name  string `acl:"public"`
+		Admin string `acl:"admin"`
+		Root  string `acl:"org:acme:root"`
+	}
+
+	for _, groups := range [][]string{{"**"}, {"adm*"}, {"org:acme:*"}, {"org:**"}} {
+		item := &Account{Name: "acme", Admin: "a", Root: "r"}
+		assert.NoError(t, Scrub(item, groups))
+		assert.Equal(t, &Account{Name: "acme"}, item, groups[0])
+	}
+
+	// a literal group containing '*' does not match tag patterns either
+	item := newStatement()
+	assert.NoError(t, Scrub(item, []string{"org:*:billing:*"}))
+	assert.Equal(t, 0, item.Total)
+
+	account := &Account{Name: "acme", Admin: "a", Root: "r"}
+	assert.NoError(t, ScrubSubject(account, Subject{Patterns: []string{"adm*"}}))
+	assert.Equal(t, &Account{Name: "acme", Admin: "a"}, account)
+}
+
+func Test_Explain_PatternPrecedence(t *testing.T) {
+
+	explanation, err := Explain(newStatement(), []string{"org:acme:billing:read"})
+	assert.NoError(t, err)
+
+	decisions := map[string]Decision{}
+	for _, d := range explanation {
+		decisions[d.Path] = d
+	}
+
+	assert.Equal(t, Decision{Path: "Total", Kept: true, Reason: ReasonPattern, Group: "org:acme:billing:read", Pattern: "org:*:billing:*", Rule: `acl:"org:*:billing:*"`, Source: SourceTag}, decisions["Total"])
+	assert.Equal(t, ReasonGroup, decisions["Summary"].Reason)
+	assert.Contains(t, explanation.String(), "matched pattern org:*:billing:* with org:acme:billing:read")
+
+	explanation, err = ExplainSubject(newStatement(), Subject{Patterns: []string{"org:**", "org:acme:**"}})
+	assert.NoError(t, err)
+	for _, d := range explanation {
+		decisions[d.Path] = d
+	}
+	assert.Equal(t, Decision{Path: "Cards", Kept: true, Reason: ReasonPattern, Group: "org:acme:billing:admin", Pattern: "org:acme:**", Rule: `acl:"org:acme:billing:admin"`, Source: SourceTag}, decisions["Cards"])
+
+	explanation, err = Explain(newStatement(), []string{"support"})
+	assert.NoError(t, err)
+	for _, d := range explanation {
+		decisions[d.Path] = d
+	}
+	assert.Equal(t, ReasonAnyGroup, decisions["Summary"].Reason)
+}
@@ -0,0 +1,347 @@
+// Copyright 2020 Alexander Zherdev. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package acllibgo
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const (
+	// PolicyOverride policy field rules replace the field 'acl' tag
+	PolicyOverride string = "override"
+	// PolicyMerge policy field rules are added as alternatives to the field 'acl' tag
+	PolicyMerge string = "merge"
+)
+
+// Policy maps fully-qualified type names to ACL rules, for types that cannot carry 'acl' tags
+// Example:
+//
+//	{
+//	  "version": "2020-06-10",
+//	  "types": {
+//	    "github.com/acme/gen.User": {
+//	      "default": "admin",
+//	      "owner": "ID",
+//	      "fields": {
+//	        "Name": {"acl": "public"},
+//	        "Email": {"acl": "admin,self"},
+//	        "Address.Street": {"acl": "admin"}
+//	      }
+//	    }
+//	  }
+//	}
+//
+// A dotted field path addresses a field of a nested struct, the rule applies to that struct type
+// wherever it appears.
+type Policy struct {
+	Version string                `json:"version,omitempty"`
+	Types   map[string]TypePolicy `json:"types"`
+}
+
+// TypePolicy holds the rules of a single struct type
+type TypePolicy struct {
+	// Mode is PolicyOverride (default) or PolicyMerge
+	Mode string `json:"mode,omitempty"`
+	// Default is the 'acl' rule of fields without a rule of their own, same as the blank _ field tag
+	Default string `json:"default,omitempty"`
+	// Owner names the field holding the owner of the struct, same as the 'aclowner' tag
+	Owner string `json:"owner,omitempty"`
+	// Fields maps field names or dotted field paths to rules
+	Fields map[string]FieldPolicy `json:"fields,omitempty"`
+}
+
+// FieldPolicy is the rule of a single field
+// An empty Acl leaves the 'acl' rule of the field as is, use "public" to open a field up.
+type FieldPolicy struct {
+	Acl string `json:"acl,omitempty"`
+	// Mask replaces the value of a denied string field instead of clearing it, e.g. "***"
+	Mask string `json:"mask,omitempty"`
+	// Mode is ModeOmit to leave the denied field out of copies and encodings, or ModeZero
+	Mode string `json:"mode,omitempty"`
+}
+
+// ParsePolicy decodes a JSON policy document
+func ParsePolicy(data []byte) (*Policy, error) {
+	rv := &Policy{}
+	if err := json.Unmarshal(data, rv); err != nil {
+		return nil, errors.New("policy: " + err.Error())
+	}
+
+	return rv, nil
+}
+
+// LoadPolicyFile reads and decodes a JSON policy file
+func LoadPolicyFile(path string) (*Policy, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.New("policy: " + err.Error())
+	}
+
+	return ParsePolicy(data)
+}
+
+// Validate checks that every type and field the policy refers to exists
+// Types are looked up among the provided types and the struct types reachable from their fields. The
+// conditions of the rules are checked too, an unknown condition or sibling field is reported.
+func (p *Policy) Validate(types ...reflect.Type) error {
+	known := make(map[string]reflect.Type)
+	for _, t := range types {
+		collectTypes(t, known)
+	}
+
+	_, err := compilePolicy(p, known)
+	return err
+}
+
+// Merge returns a new policy with the rules of other added to the rules of p
+// For a type present in both, the non-empty mode, default, owner and field settings of other replace
+// those of p. The result carries the version of other.
+func (p *Policy) Merge(other *Policy) *Policy {
+	rv := &Policy{Types: make(map[string]TypePolicy)}
+	if other != nil {
+		rv.Version = other.Version
+	}
+
+	for _, src := range []*Policy{p, other} {
+		if src == nil {
+			continue
+		}
+
+		for name, tp := range src.Types {
+			merged := rv.Types[name]
+			if len(tp.Mode) > 0 {
+				merged.Mode = tp.Mode
+			}
+			if len(tp.Default) > 0 {
+				merged.Default = tp.Default
+			}
+			if len(tp.Owner) > 0 {
+				merged.Owner = tp.Owner
+			}
+			if merged.Fields == nil {
+				merged.Fields = make(map[string]FieldPolicy)
+			}
+			for path, fp := range tp.Fields {
+				mergedField := merged.Fields[path]
+				if len(fp.Acl) > 0 {
+					mergedField.Acl = fp.Acl
+				}
+				if len(fp.Mask) > 0 {
+					mergedField.Mask = fp.Mask
+				}
+				if len(fp.Mode) > 0 {
+					mergedField.Mode = fp.Mode
+				}
+				merged.Fields[path] = mergedField
+			}
+			rv.Types[name] = merged
+		}
+	}
+
+	return rv
+}
+
+// TypeName returns the fully-qualified name policies use for the type, e.g. github.com/acme/gen.User
+func TypeName(t reflect.Type) string {
+	if len(t.PkgPath()) == 0 {
+		return t.Name()
+	}
+	return t.PkgPath() + "." + t.Name()
+}
+
+// typeRules are the policy rules compiled against a struct type
+type typeRules struct {
+	Default    string
+	OwnerField int
+	Fields     map[int]fieldRule
+}
+
+type fieldRule struct {
+	Acl   string
+	Mask  string
+	Mode  string
+	Merge bool
+}
+
+// compilePolicy resolves the type names and field paths of the policy against the known types
+// Every problem found is reported in the returned error.
+func compilePolicy(p *Policy, known map[string]reflect.Type) (map[reflect.Type]*typeRules, error) {
+	rv := make(map[reflect.Type]*typeRules)
+	problems := []string{}
+
+	rulesOf := func(t reflect.Type) *typeRules {
+		if rv[t] == nil {
+			rv[t] = &typeRules{OwnerField: -1, Fields: make(map[int]fieldRule)}
+		}
+		return rv[t]
+	}
+
+	names := make([]string, 0, len(p.Types))
+	for name := range p.Types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		tp := p.Types[name]
+		t, ok := known[name]
+		if !ok {
+			problems = append(problems, "unknown type "+name)
+			continue
+		}
+
+		if len(tp.Mode) > 0 && tp.Mode != PolicyOverride && tp.Mode != PolicyMerge {
+			problems = append(problems, name+": unknown mode "+tp.Mode)
+		}
+
+		rules := rulesOf(t)
+		rules.Default = strings.TrimSpace(tp.Default)
+		for _, problem := range checkConditions(getTypeInfo(t), parseAcl(rules.Default)) {
+			problems = append(problems, name+": default: "+problem)
+		}
+
+		if len(tp.Owner) > 0 {
+			if index, ok := fieldIndex(t, tp.Owner); ok {
+				rules.OwnerField = index
+			} else {
+				problems = append(problems, name+": unknown owner field "+tp.Owner)
+			}
+		}
+
+		paths := make([]string, 0, len(tp.Fields))
+		for path := range tp.Fields {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+
+		for _, path := range paths {
+			owner, index, ok := resolveFieldPath(t, path)
+			if !ok {
+				problems = append(problems, name+": unknown field "+path)
+				continue
+			}
+
+			fp := tp.Fields[path]
+			if fp.Mode != "" && fp.Mode != ModeZero && fp.Mode != ModeOmit {
+				problems = append(problems, name+": unknown mode for field "+path)
+				continue
+			}
+
+			rule := fieldRule{Acl: strings.TrimSpace(fp.Acl), Mask: fp.Mask, Mode: fp.Mode, Merge: tp.Mode == PolicyMerge}
+			if conditions := checkConditions(getTypeInfo(owner), parseAcl(rule.Acl)); len(conditions) > 0 {
+				problems = append(problems, name+": field "+path+": "+strings.Join(conditions, ", "))
+				continue
+			}
+			ownerRules := rulesOf(owner)
+			if existing, found := ownerRules.Fields[index]; found && existing != rule {
+				problems = append(problems, name+": conflicting rules for field "+path)
+				continue
+			}
+			ownerRules.Fields[index] = rule
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, errors.New("policy: " + strings.Join(problems, "; "))
+	}
+
+	return rv, nil
+}
+
+// fieldIndex finds the field of the struct type by name, ignoring case like Keep and Zero
+func fieldIndex(t reflect.Type, name string) (int, bool) {
+	info := getTypeInfo(t)
+	for x, f := range info.Field {
+		if f.Exported && strings.EqualFold(f.Name, name) {
+			return x, true
+		}
+	}
+	return -1, false
+}
+
+// resolveFieldPath walks a dotted field path from the struct type, returning the struct declaring the last field
+func resolveFieldPath(t reflect.Type, path string) (reflect.Type, int, bool) {
+	names := strings.Split(path, ".")
+	for x, name := range names {
+		index, ok := fieldIndex(t, name)
+		if !ok {
+			return nil, -1, false
+		}
+		if x == len(names)-1 {
+			return t, index, true
+		}
+
+		t, ok = structElem(t.Field(index).Type)
+		if !ok {
+			return nil, -1, false
+		}
+	}
+
+	return nil, -1, false
+}
+
+// collectTypes registers the struct type and every struct type reachable from its fields by name
+func collectTypes(t reflect.Type, known map[string]reflect.Type) {
+	t, ok := structElem(t)
+	if !ok || len(t.Name()) == 0 {
+		return
+	}
+
+	name := TypeName(t)
+	if _, found := known[name]; found {
+		return
+	}
+	known[name] = t
+
+	for _, f := range getTypeInfo(t).Field {
+		if f.Exported {
+			collectTypes(f.Type, known)
+		}
+	}
+}
+
+// apply overlays the compiled policy rules on the tag information of the type
+func (rules *typeRules) apply(info typeInfo) typeInfo {
+	fields := make([]fieldInfo, len(info.Field))
+	copy(fields, info.Field)
+	info.Field = fields
+
+	if rules.OwnerField >= 0 {
+		info.OwnerField = rules.OwnerField
+	}
+
+	if len(rules.Default) > 0 {
+		info.DefaultAcl = rules.Default
+		for x := range info.Field {
+			if info.Field[x].AclSource != SourceTag {
+				info.Field[x].setRule(rules.Default, SourcePolicy)
+			}
+		}
+	}
+
+	for x, rule := range rules.Fields {
+		f := &info.Field[x]
+		if len(rule.Acl) > 0 {
+			if rule.Merge && len(f.AclRule) > 0 {
+				f.setRule(f.AclRule+","+rule.Acl, SourcePolicy)
+			} else {
+				f.setRule(rule.Acl, SourcePolicy)
+			}
+		}
+		if len(rule.Mask) > 0 {
+			f.Mask = rule.Mask
+		}
+		if len(rule.Mode) > 0 {
+			f.Omit = rule.Mode == ModeOmit
+		}
+	}
+
+	return info
+}
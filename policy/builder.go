@@ -0,0 +1,185 @@
+// Copyright 2020 Alexander Zherdev. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package policy declares ACL rules in Go for types that cannot carry 'acl' tags
+//
+//	err := policy.For[gen.User]().
+//		Default("admin").
+//		Field("Name").Public().
+//		Field("Token").Allow("root").
+//		Field("Email").Allow("admin", "self").Mask("***").
+//		Register(engine)
+//
+// The builder produces an acllibgo.Policy, the rules compile to the same per-type plan as tags and
+// policy files do.
+package policy
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+
+	"github.com/mralexzee/acllibgo"
+)
+
+// Builder declares the rules of the struct type T
+type Builder[T any] struct {
+	typ    reflect.Type
+	rules  acllibgo.TypePolicy
+	errors []string
+}
+
+// FieldBuilder declares the rule of a single field of T
+type FieldBuilder[T any] struct {
+	builder *Builder[T]
+	path    string
+	typ     reflect.Type
+}
+
+// For starts the rules of the struct type T
+func For[T any]() *Builder[T] {
+	b := &Builder[T]{
+		typ:   reflect.TypeOf((*T)(nil)).Elem(),
+		rules: acllibgo.TypePolicy{Fields: make(map[string]acllibgo.FieldPolicy)},
+	}
+	if b.typ.Kind() != reflect.Struct || len(b.typ.Name()) == 0 {
+		b.errors = append(b.errors, "expecting named struct, got "+b.typ.String())
+	}
+
+	return b
+}
+
+// Default sets the rule of fields without a rule of their own, same as _ struct{} `acl:"..."`
+func (b *Builder[T]) Default(acl ...string) *Builder[T] {
+	b.rules.Default = strings.Join(acl, ",")
+	return b
+}
+
+// Owner names the field holding the owner of the struct, same as the 'aclowner' tag
+func (b *Builder[T]) Owner(field string) *Builder[T] {
+	if b.resolve(field) != nil {
+		b.rules.Owner = field
+	}
+	return b
+}
+
+// Merge adds the field rules as alternatives to the 'acl' tags instead of replacing them
+func (b *Builder[T]) Merge() *Builder[T] {
+	b.rules.Mode = acllibgo.PolicyMerge
+	return b
+}
+
+// Field starts the rule of a field, nested fields are addressed with a dotted path such as Address.Street
+func (b *Builder[T]) Field(path string) *FieldBuilder[T] {
+	return &FieldBuilder[T]{builder: b, path: path, typ: b.resolve(path)}
+}
+
+// Build returns the policy declared so far, or every unknown field name and misplaced mask found
+func (b *Builder[T]) Build() (*acllibgo.Policy, error) {
+	if len(b.errors) > 0 {
+		return nil, errors.New("policy: " + b.typ.String() + ": " + strings.Join(b.errors, "; "))
+	}
+
+	p := &acllibgo.Policy{Types: map[string]acllibgo.TypePolicy{acllibgo.TypeName(b.typ): b.rules}}
+	if err := p.Validate(b.typ); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// Register builds the policy and merges it into the active policy of the engine
+func (b *Builder[T]) Register(e *acllibgo.Engine) error {
+	p, err := b.Build()
+	if err != nil {
+		return err
+	}
+
+	e.RegisterTypes(b.typ)
+	return e.MergePolicy(p)
+}
+
+// resolve checks the dotted field path against T and returns the field type, nil when the field is unknown
+// Field names are case-sensitive.
+func (b *Builder[T]) resolve(path string) reflect.Type {
+	t := b.typ
+	for _, name := range strings.Split(path, ".") {
+		structType, ok := structElem(t)
+		if !ok {
+			b.errors = append(b.errors, "unknown field "+path)
+			return nil
+		}
+
+		field, ok := structType.FieldByName(name)
+		if !ok || len(field.Index) != 1 || len(field.PkgPath) > 0 {
+			b.errors = append(b.errors, "unknown field "+path)
+			return nil
+		}
+		t = field.Type
+	}
+
+	return t
+}
+
+// Allow keeps the field for the groups and keywords (public, self, @predicate) listed
+func (f *FieldBuilder[T]) Allow(acl ...string) *FieldBuilder[T] {
+	fp := f.builder.rules.Fields[f.path]
+	fp.Acl = strings.Join(acl, ",")
+	f.builder.rules.Fields[f.path] = fp
+	return f
+}
+
+// Public keeps the field for everyone, same as Allow("public")
+func (f *FieldBuilder[T]) Public() *FieldBuilder[T] {
+	return f.Allow("public")
+}
+
+// Mask replaces the value of the string field with mask when it is denied, instead of clearing it
+// A mask on a field of another kind is reported by Build.
+func (f *FieldBuilder[T]) Mask(mask string) *FieldBuilder[T] {
+	if f.typ != nil && f.typ.Kind() != reflect.String {
+		f.builder.errors = append(f.builder.errors, "mask on non-string field "+f.path)
+	}
+	fp := f.builder.rules.Fields[f.path]
+	fp.Mask = mask
+	f.builder.rules.Fields[f.path] = fp
+	return f
+}
+
+// Omit leaves the field out of copies and encodings when it is denied, instead of clearing it
+func (f *FieldBuilder[T]) Omit() *FieldBuilder[T] {
+	fp := f.builder.rules.Fields[f.path]
+	fp.Mode = acllibgo.ModeOmit
+	f.builder.rules.Fields[f.path] = fp
+	return f
+}
+
+// Field starts the rule of the next field
+func (f *FieldBuilder[T]) Field(path string) *FieldBuilder[T] {
+	return f.builder.Field(path)
+}
+
+// Build returns the policy declared so far, see Builder.Build
+func (f *FieldBuilder[T]) Build() (*acllibgo.Policy, error) {
+	return f.builder.Build()
+}
+
+// Register builds the policy and merges it into the active policy of the engine, see Builder.Register
+func (f *FieldBuilder[T]) Register(e *acllibgo.Engine) error {
+	return f.builder.Register(e)
+}
+
+// structElem unwraps pointers, slices, arrays, and maps until it reaches a struct type
+func structElem(t reflect.Type) (reflect.Type, bool) {
+	for {
+		switch t.Kind() {
+		case reflect.Struct:
+			return t, true
+		case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+			t = t.Elem()
+		default:
+			return nil, false
+		}
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright 2020 Alexander Zherdev. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package policy
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mralexzee/acllibgo"
+	"github.com/stretchr/testify/assert"
+)
+
+type User struct {
+	ID      string
+	Name    string
+	Email   string
+	Token   string
+	Age     int
+	Address *Address
+}
+
+type Address struct {
+	Street string
+	City   string
+}
+
+func newUser() User {
+	return User{ID: "u1", Name: "John", Email: "john@example.com", Token: "t", Age: 30, Address: &Address{Street: "Main St", City: "Springfield"}}
+}
+
+func Test_Builder_Register(t *testing.T) {
+
+	e := acllibgo.NewEngine(acllibgo.Options{})
+	err := For[User]().
+		Default("admin").
+		Owner("ID").
+		Field("ID").Public().
+		Field("Name").Public().
+		Field("Token").Allow("root").
+		Field("Email").Allow("admin", "self").Mask("***").
+		Field("Address").Public().
+		Field("Address.Street").Allow("admin").
+		Register(e)
+	assert.NoError(t, err)
+
+	testItem := newUser()
+	assert.NoError(t, e.Scrub(&testItem, []string{"user"}))
+	assert.Equal(t, User{ID: "u1", Name: "John", Email: "***", Address: &Address{City: "Springfield"}}, testItem)
+
+	testItem = newUser()
+	assert.NoError(t, e.ScrubSubject(&testItem, acllibgo.Subject{ID: "u1", Groups: []string{"user"}}))
+	assert.Equal(t, "john@example.com", testItem.Email)
+
+	testItem = newUser()
+	report, err := e.ScrubWithReport(&testItem, []string{"admin"})
+	assert.NoError(t, err)
+	assert.Equal(t, []acllibgo.Redaction{{Path: "Token", Rule: `acl:"root"`, Action: acllibgo.ActionZero}}, report)
+}
+
+func Test_Builder_SamePlanAsTags(t *testing.T) {
+
+	type Tagged struct {
+		ID    string `acl:"public"`
+		Email string `acl:"admin,self"`
+		Token string `acl:"root"`
+		Note  string
+	}
+	type Untagged struct {
+		ID    string
+		Email string
+		Token string
+		Note  string
+	}
+
+	e := acllibgo.NewEngine(acllibgo.Options{DenyUntagged: true})
+	err := For[Untagged]().
+		Field("ID").Public().
+		Field("Email").Allow("admin", "self").
+		Field("Token").Allow("root").
+		Register(e)
+	assert.NoError(t, err)
+
+	for _, groups := range [][]string{{}, {"admin"}, {"root"}} {
+		tagged := Tagged{ID: "1", Email: "e", Token: "t", Note: "n"}
+		untagged := Untagged{ID: "1", Email: "e", Token: "t", Note: "n"}
+
+		taggedExplanation, err := e.Explain(&tagged, groups)
+		assert.NoError(t, err)
+		untaggedExplanation, err := e.Explain(&untagged, groups)
+		assert.NoError(t, err)
+		for x := range taggedExplanation[:3] {
+			assert.Equal(t, acllibgo.SourceTag, taggedExplanation[x].Source)
+			taggedExplanation[x].Source = acllibgo.SourcePolicy
+		}
+		assert.Equal(t, taggedExplanation[:3], untaggedExplanation[:3])
+
+		assert.NoError(t, e.Scrub(&tagged, groups))
+		assert.NoError(t, e.Scrub(&untagged, groups))
+		assert.Equal(t, Untagged(tagged), untagged)
+	}
+}
+
+func Test_Builder_Typos(t *testing.T) {
+
+	_, err := For[User]().
+		Owner("Owner").
+		Field("Emial").Allow("admin").
+		Field("Address.Stret").Allow("admin").
+		Build()
+	assert.EqualError(t, err, "policy: policy.User: unknown field Owner; unknown field Emial; unknown field Address.Stret")
+
+	_, err = For[int]().Build()
+	assert.Error(t, err)
+
+	e := acllibgo.NewEngine(acllibgo.Options{})
+	assert.Error(t, For[User]().Field("token").Allow("admin").Register(e))
+	assert.Equal(t, "", e.PolicyVersion())
+}
+
+func Test_Builder_MaskNonString(t *testing.T) {
+
+	_, err := For[User]().
+		Field("Age").Allow("admin").Mask("***").
+		Field("Address").Mask("***").
+		Field("Name").Mask("***").
+		Build()
+	assert.EqualError(t, err, "policy: policy.User: mask on non-string field Age; mask on non-string field Address")
+}
+
+func Test_Builder_Conditions(t *testing.T) {
+
+	_, err := For[User]().
+		Field("Token").Allow("admin@after(expires_at)").
+		Field("Address.Street").Allow("@flag(support)", "@weekday(Street)").
+		Build()
+	assert.EqualError(t, err, "policy: github.com/mralexzee/acllibgo/policy.User: field Address.Street: unknown condition weekday(Street); "+
+		"github.com/mralexzee/acllibgo/policy.User: field Token: unknown field expires_at in after(expires_at)")
+}
+
+func Test_Builder_MergeRegistrations(t *testing.T) {
+
+	e := acllibgo.NewEngine(acllibgo.Options{})
+	assert.NoError(t, For[User]().Field("Token").Allow("root").Register(e))
+	assert.NoError(t, For[User]().Field("Email").Allow("admin").Register(e))
+	assert.NoError(t, For[Address]().Field("Street").Allow("admin").Register(e))
+
+	testItem := newUser()
+	assert.NoError(t, e.Scrub(&testItem, []string{"user"}))
+	assert.Equal(t, "", testItem.Token)
+	assert.Equal(t, "", testItem.Email)
+	assert.Equal(t, "", testItem.Address.Street)
+	assert.Equal(t, "John", testItem.Name)
+}
+
+func Test_Builder_Omit(t *testing.T) {
+
+	e := acllibgo.NewEngine(acllibgo.Options{})
+	assert.NoError(t, For[User]().Field("Token").Allow("root").Omit().Register(e))
+
+	fields := e.NewEvaluator(acllibgo.Subject{}).Fields(reflect.TypeOf(User{}))
+	assert.True(t, fields[3].Omit)
+	assert.Equal(t, "root", fields[3].Rule)
+}
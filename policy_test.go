@@ -0,0 +1,210 @@
+// Copyright 2020 Alexander Zherdev. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package acllibgo
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// GenUser stands in for a generated type that cannot carry 'acl' tags
+type GenUser struct {
+	ID      string
+	Name    string
+	Email   string
+	Token   string `acl:"root"`
+	Address *GenAddress
+}
+
+type GenAddress struct {
+	Street string
+	City   string
+}
+
+func newGenUser() GenUser {
+	return GenUser{ID: "u1", Name: "John", Email: "john@example.com", Token: "t", Address: &GenAddress{Street: "Main St", City: "Springfield"}}
+}
+
+const genPolicy = `{
+  "version": "v1",
+  "types": {
+    "github.com/mralexzee/acllibgo.GenUser": {
+      "default": "admin",
+      "owner": "ID",
+      "fields": {
+        "Name": {"acl": "public"},
+        "Email": {"acl": "admin,self"},
+        "Address": {"acl": "public"},
+        "Address.Street": {"acl": "admin"}
+      }
+    }
+  }
+}`
+
+func newPolicyEngine(t *testing.T, text string) *Engine {
+	e := NewEngine(Options{})
+	e.RegisterTypes(reflect.TypeOf(GenUser{}))
+	p, err := ParsePolicy([]byte(text))
+	assert.NoError(t, err)
+	assert.NoError(t, e.SetPolicy(p))
+	return e
+}
+
+func Test_Policy_Override(t *testing.T) {
+
+	e := newPolicyEngine(t, genPolicy)
+
+	testItem := newGenUser()
+	err := e.ScrubSubject(&testItem, Subject{ID: "u1", Groups: []string{"user"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "John", testItem.Name)
+	assert.Equal(t, "john@example.com", testItem.Email)
+	assert.Equal(t, "", testItem.Token)
+	assert.Equal(t, "", testItem.Address.Street)
+	assert.Equal(t, "Springfield", testItem.Address.City)
+
+	// the tag keeps precedence over the policy default
+	testItem = newGenUser()
+	err = e.Scrub(&testItem, []string{"admin"})
+	assert.NoError(t, err)
+	assert.Equal(t, "", testItem.Token)
+	assert.Equal(t, "Main St", testItem.Address.Street)
+
+	// the package level engine is not affected
+	testItem = newGenUser()
+	err = Scrub(&testItem, []string{"user"})
+	assert.NoError(t, err)
+	assert.Equal(t, "john@example.com", testItem.Email)
+	assert.Equal(t, "Main St", testItem.Address.Street)
+}
+
+func Test_Policy_Merge(t *testing.T) {
+
+	e := newPolicyEngine(t, `{"types": {"github.com/mralexzee/acllibgo.GenUser": {"mode": "merge", "fields": {"Token": {"acl": "admin"}}}}}`)
+
+	for _, group := range []string{"root", "admin"} {
+		testItem := newGenUser()
+		assert.NoError(t, e.Scrub(&testItem, []string{group}))
+		assert.Equal(t, "t", testItem.Token)
+	}
+
+	testItem := newGenUser()
+	assert.NoError(t, e.Scrub(&testItem, []string{"user"}))
+	assert.Equal(t, "", testItem.Token)
+}
+
+func Test_Policy_Explain(t *testing.T) {
+
+	e := newPolicyEngine(t, genPolicy)
+
+	testItem := newGenUser()
+	explanation, err := e.Explain(&testItem, []string{"admin"})
+	assert.NoError(t, err)
+	assert.Contains(t, explanation, Decision{Path: "ID", Kept: true, Reason: ReasonGroup, Group: "admin", Rule: `acl:"admin"`, Source: SourcePolicy})
+	assert.Contains(t, explanation, Decision{Path: "Token", Kept: false, Action: ActionZero, Reason: ReasonNoMatch, Rule: `acl:"root"`, Source: SourceTag})
+	assert.Contains(t, explanation, Decision{Path: "Address.Street", Kept: true, Reason: ReasonGroup, Group: "admin", Rule: `acl:"admin"`, Source: SourcePolicy})
+}
+
+func Test_Policy_Validation(t *testing.T) {
+
+	e := NewEngine(Options{})
+	e.RegisterTypes(reflect.TypeOf(&GenUser{}))
+
+	p, err := ParsePolicy([]byte(`{"types": {
+		"github.com/mralexzee/acllibgo.Unknown": {},
+		"github.com/mralexzee/acllibgo.GenAddress": {"mode": "replace", "owner": "Owner", "fields": {"Zip": {"acl": "admin"}}},
+		"github.com/mralexzee/acllibgo.GenUser": {"fields": {"Address.Country": {"acl": "admin"}, "Email": {"acl": "admin"}}}
+	}}`))
+	assert.NoError(t, err)
+
+	err = e.SetPolicy(p)
+	assert.EqualError(t, err, "policy: github.com/mralexzee/acllibgo.GenAddress: unknown mode replace; "+
+		"github.com/mralexzee/acllibgo.GenAddress: unknown owner field Owner; "+
+		"github.com/mralexzee/acllibgo.GenAddress: unknown field Zip; "+
+		"github.com/mralexzee/acllibgo.GenUser: unknown field Address.Country; "+
+		"unknown type github.com/mralexzee/acllibgo.Unknown")
+	assert.Nil(t, e.Policy())
+
+	_, err = ParsePolicy([]byte(`{"types": [`))
+	assert.Error(t, err)
+}
+
+func Test_Policy_Conflict(t *testing.T) {
+
+	e := NewEngine(Options{})
+	e.RegisterTypes(reflect.TypeOf(GenUser{}))
+
+	p, err := ParsePolicy([]byte(`{"types": {
+		"github.com/mralexzee/acllibgo.GenAddress": {"fields": {"City": {"acl": "user"}}},
+		"github.com/mralexzee/acllibgo.GenUser": {"fields": {"Address.City": {"acl": "admin"}}}
+	}}`))
+	assert.NoError(t, err)
+	assert.EqualError(t, e.SetPolicy(p), "policy: github.com/mralexzee/acllibgo.GenUser: conflicting rules for field Address.City")
+}
+
+func Test_Policy_File(t *testing.T) {
+
+	f, err := ioutil.TempFile("", "policy-*.json")
+	assert.NoError(t, err)
+	defer os.Remove(f.Name())
+	f.WriteString(genPolicy)
+	f.Close()
+
+	p, err := LoadPolicyFile(f.Name())
+	assert.NoError(t, err)
+	assert.Equal(t, "v1", p.Version)
+	assert.Equal(t, "admin,self", p.Types["github.com/mralexzee/acllibgo.GenUser"].Fields["Email"].Acl)
+
+	_, err = LoadPolicyFile(f.Name() + ".missing")
+	assert.Error(t, err)
+}
+
+func Test_Policy_Remove(t *testing.T) {
+
+	e := newPolicyEngine(t, genPolicy)
+	assert.NoError(t, e.SetPolicy(nil))
+
+	testItem := newGenUser()
+	assert.NoError(t, e.Scrub(&testItem, []string{"user"}))
+	assert.Equal(t, "john@example.com", testItem.Email)
+}
+
+func Test_Policy_MergeMask(t *testing.T) {
+
+	e := newPolicyEngine(t, genPolicy)
+	p, err := ParsePolicy([]byte(`{"types": {"github.com/mralexzee/acllibgo.GenUser": {"fields": {"Email": {"mask": "***"}}}}}`))
+	assert.NoError(t, err)
+	assert.NoError(t, p.Validate(reflect.TypeOf(GenUser{})))
+	assert.NoError(t, e.MergePolicy(p))
+	assert.Equal(t, "2", e.PolicyVersion())
+
+	testItem := newGenUser()
+	report, err := e.ScrubWithReport(&testItem, []string{"user"})
+	assert.NoError(t, err)
+	assert.Equal(t, "***", testItem.Email)
+	assert.Equal(t, "John", testItem.Name)
+	assert.Contains(t, report, Redaction{Path: "Email", Rule: `acl:"admin,self"`, Action: ActionMask})
+}
+
+func Test_Policy_Mode(t *testing.T) {
+
+	e := newPolicyEngine(t, genPolicy)
+	p, err := ParsePolicy([]byte(`{"types": {"github.com/mralexzee/acllibgo.GenUser": {"fields": {"Email": {"mode": "omit"}}}}}`))
+	assert.NoError(t, err)
+	assert.NoError(t, e.MergePolicy(p))
+
+	fields := e.NewEvaluator(Subject{}).Fields(reflect.TypeOf(GenUser{}))
+	assert.True(t, fields[2].Omit)
+	assert.False(t, fields[3].Omit)
+	assert.Equal(t, "admin,self", fields[2].Rule)
+
+	p, err = ParsePolicy([]byte(`{"types": {"github.com/mralexzee/acllibgo.GenUser": {"fields": {"Email": {"mode": "drop"}}}}}`))
+	assert.NoError(t, err)
+	assert.EqualError(t, p.Validate(reflect.TypeOf(GenUser{})), "policy: github.com/mralexzee/acllibgo.GenUser: unknown mode for field Email")
+}
@@ -0,0 +1,280 @@
+// Copyright 2020 Alexander Zherdev. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package acllibgo
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Project returns the item as nested maps keyed by Go field names, with the ACLs and the selector applied
+// The item is a struct or a pointer to a struct and is left untouched. A field is present when the selector
+// lists it, as in Keep, and denied fields are cleared or masked as by Scrub, or left out when tagged
+// aclmode:"omit". A nil selector selects every field. Pointers, slices, arrays and maps are followed
+// and struct values become maps as well, so their ACLs apply too, while time.Time and types marshaling
+// themselves are kept as they are.
+func Project(item interface{}, groups []string, fields []StructField) (map[string]interface{}, error) {
+	return defaultEngine.Project(item, groups, fields)
+}
+
+// ProjectJSON returns the item like Project with maps keyed by JSON names
+// Fields tagged json:"-" and empty omitempty fields are left out, embedded structs are promoted.
+func ProjectJSON(item interface{}, groups []string, fields []StructField) (map[string]interface{}, error) {
+	return defaultEngine.ProjectJSON(item, groups, fields)
+}
+
+// Project returns the item as nested maps keyed by Go field names, see Project
+func (e *Engine) Project(item interface{}, groups []string, fields []StructField) (map[string]interface{}, error) {
+	return e.project(item, groups, fields, false)
+}
+
+// ProjectJSON returns the item as nested maps keyed by JSON names, see ProjectJSON
+func (e *Engine) ProjectJSON(item interface{}, groups []string, fields []StructField) (map[string]interface{}, error) {
+	return e.project(item, groups, fields, true)
+}
+
+func (e *Engine) project(item interface{}, groups []string, fields []StructField, jsonNames bool) (map[string]interface{}, error) {
+	if groups == nil {
+		return nil, errors.New("project: nil acl")
+	}
+	if item == nil {
+		return nil, errors.New("project: nil item")
+	}
+
+	itemValue := reflect.ValueOf(item)
+	if itemValue.Kind() == reflect.Ptr {
+		if itemValue.IsNil() {
+			return nil, errors.New("project: nil " + itemValue.Type().String())
+		}
+		itemValue = itemValue.Elem()
+	}
+	if itemValue.Kind() != reflect.Struct {
+		return nil, errors.New("project: expecting struct, got " + itemValue.Type().String())
+	}
+
+	p := &projection{engine: e, eval: e.newEvaluation(&Subject{Groups: groups}), jsonNames: jsonNames, visiting: map[uintptr]bool{}}
+	rv := make(map[string]interface{})
+	if err := p.projectStruct(rv, itemValue, fields); err != nil {
+		return nil, err
+	}
+
+	return rv, nil
+}
+
+type projection struct {
+	engine    *Engine
+	eval      *evaluation
+	jsonNames bool
+	visiting  map[uintptr]bool
+}
+
+// projectStruct adds the fields of the struct value to the map
+func (p *projection) projectStruct(rv map[string]interface{}, v reflect.Value, fields []StructField) error {
+	info := p.eval.typeInfo(v.Type())
+	for i, f := range info.Field {
+		if !f.Exported || (p.jsonNames && f.JsonName == "-") {
+			continue
+		}
+
+		selected, nested := selectField(fields, f)
+		if !selected {
+			continue
+		}
+
+		fv := info.value(v, i)
+		if !p.engine.allows(&info, &f, p.eval, v) {
+			if f.Omit {
+				continue
+			}
+			fv = reflect.New(f.Type).Elem()
+			if len(f.Mask) > 0 && f.Kind == reflect.String {
+				fv.SetString(f.Mask)
+			}
+		}
+
+		if p.jsonNames && f.Anonymous && !hasJsonName(v.Type().Field(i)) {
+			if embedded, ok := p.embedded(fv); ok {
+				if err := p.projectEmbedded(rv, embedded, nested); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		if p.jsonNames && f.OmitEmpty && isEmpty(fv) {
+			continue
+		}
+
+		value, err := p.projectValue(fv, nested)
+		if err != nil {
+			return err
+		}
+
+		name := f.Name
+		if p.jsonNames {
+			name = f.JsonName
+		}
+		rv[name] = value
+	}
+
+	return nil
+}
+
+// projectEmbedded promotes the fields of an embedded struct, fields of the outer struct take precedence
+func (p *projection) projectEmbedded(rv map[string]interface{}, v reflect.Value, fields []StructField) error {
+	promoted := make(map[string]interface{})
+	if err := p.projectStruct(promoted, v, fields); err != nil {
+		return err
+	}
+	for k, value := range promoted {
+		if _, ok := rv[k]; !ok {
+			rv[k] = value
+		}
+	}
+	return nil
+}
+
+func (p *projection) embedded(v reflect.Value) (reflect.Value, bool) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return v, false
+		}
+		v = v.Elem()
+	}
+	return v, v.Kind() == reflect.Struct && !marshalsItself(v.Type()) && !marshalsItself(reflect.PtrTo(v.Type()))
+}
+
+// projectValue converts the value to maps, slices and plain values
+func (p *projection) projectValue(v reflect.Value, fields []StructField) (interface{}, error) {
+	if !v.IsValid() {
+		return nil, nil
+	}
+	if marshalsItself(v.Type()) {
+		return v.Interface(), nil
+	}
+	if v.CanAddr() && marshalsItself(reflect.PtrTo(v.Type())) {
+		return v.Addr().Interface(), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil, nil
+		}
+		addr := v.Pointer()
+		if p.visiting[addr] {
+			return nil, errors.New("project: cycle through " + v.Type().String())
+		}
+		p.visiting[addr] = true
+		defer delete(p.visiting, addr)
+		return p.projectValue(v.Elem(), fields)
+	case reflect.Interface:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return p.projectValue(v.Elem(), fields)
+	case reflect.Struct:
+		rv := make(map[string]interface{})
+		if err := p.projectStruct(rv, v, fields); err != nil {
+			return nil, err
+		}
+		return rv, nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil, nil
+		}
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			return append([]byte(nil), v.Bytes()...), nil
+		}
+		rv := make([]interface{}, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			value, err := p.projectValue(v.Index(i), fields)
+			if err != nil {
+				return nil, err
+			}
+			rv[i] = value
+		}
+		return rv, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return nil, nil
+		}
+		rv := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			value, err := p.projectValue(iter.Value(), fields)
+			if err != nil {
+				return nil, err
+			}
+			rv[mapKey(iter.Key())] = value
+		}
+		return rv, nil
+	}
+
+	return v.Interface(), nil
+}
+
+// selectField reports whether the selector lists the field, as in Keep, and returns its nested selector
+func selectField(fields []StructField, f fieldInfo) (bool, []StructField) {
+	if fields == nil {
+		return true, nil
+	}
+	for _, k := range fields {
+		if k.Name == "*" || strings.EqualFold(k.Name, f.Name) || strings.EqualFold(k.Name, f.JsonName) {
+			return true, k.Fields
+		}
+	}
+	return false, nil
+}
+
+func marshalsItself(t reflect.Type) bool {
+	if t == timeType {
+		return true
+	}
+	if t.Kind() == reflect.Interface {
+		return false
+	}
+	return t.Implements(jsonMarshalerType) || t.Implements(textMarshalerType)
+}
+
+func hasJsonName(field reflect.StructField) bool {
+	tag := field.Tag.Get("json")
+	return len(tag) > 0 && tag[0] != ','
+}
+
+// mapKey renders a map key as text, as encoding/json does for strings, integers and text marshalers
+func mapKey(k reflect.Value) string {
+	if k.Kind() == reflect.String {
+		return k.String()
+	}
+	if tm, ok := k.Interface().(encoding.TextMarshaler); ok {
+		if text, err := tm.MarshalText(); err == nil {
+			return string(text)
+		}
+	}
+	return fmt.Sprint(k.Interface())
+}
+
+// isEmpty reports whether encoding/json leaves the value out of an omitempty field
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
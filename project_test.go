@@ -0,0 +1,132 @@
+// Copyright 2020 Alexander Zherdev. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package acllibgo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type Shelter struct {
+	Name    string
+	Contact string          `json:"contact" acl:"staff"`
+	Cats    []*Cat          `json:"cats"`
+	Lead    *Cat            `json:"lead,omitempty"`
+	Rooms   map[int]*Cat    `json:"rooms"`
+	Tags    map[string]bool `json:"-"`
+	Secret  string          `json:"secret" acl:"root" aclmode:"omit"`
+	Code    string          `json:"code" acl:"root"`
+	Notes   interface{}     `json:"notes"`
+	private string
+}
+
+// Branch embeds Shelter, its fields are promoted by ProjectJSON
+type Branch struct {
+	*Shelter
+	City string `json:"city"`
+}
+
+func newShelter() *Shelter {
+	fluffy := &Cat{Name: "Fluffy", Type: "furry"}
+	return &Shelter{
+		Name:    "Paws",
+		Contact: "555",
+		Cats:    []*Cat{fluffy, nil},
+		Rooms:   map[int]*Cat{1: fluffy},
+		Tags:    map[string]bool{"open": true},
+		Secret:  "s",
+		Code:    "c",
+		Notes:   Cat{Name: "Tom", Type: "tabby"},
+		private: "p",
+	}
+}
+
+func Test_Project(t *testing.T) {
+
+	item := newShelter()
+	rv, err := Project(item, []string{"root"}, nil)
+	assert.NoError(t, err)
+
+	fluffy := map[string]interface{}{"Name": "Fluffy", "Type": "furry"}
+	assert.Equal(t, map[string]interface{}{
+		"Name":    "Paws",
+		"Contact": "",
+		"Cats":    []interface{}{fluffy, nil},
+		"Lead":    nil,
+		"Rooms":   map[string]interface{}{"1": fluffy},
+		"Tags":    map[string]interface{}{"open": true},
+		"Secret":  "s",
+		"Code":    "c",
+		"Notes":   map[string]interface{}{"Name": "Tom", "Type": "tabby"},
+	}, rv)
+	assert.Equal(t, newShelter(), item)
+}
+
+func Test_Project_Denied(t *testing.T) {
+
+	rv, err := Project(newShelter(), []string{"account"}, nil)
+	assert.NoError(t, err)
+
+	assert.NotContains(t, rv, "Secret")
+	assert.Equal(t, "", rv["Code"])
+	assert.Equal(t, "", rv["Contact"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"Name": "Fluffy", "Type": ""}, nil}, rv["Cats"])
+	assert.Equal(t, map[string]interface{}{"Name": "Tom", "Type": ""}, rv["Notes"])
+}
+
+func Test_Project_Selector(t *testing.T) {
+
+	fields, err := Parse("name,cats(name),secret,notes")
+	assert.NoError(t, err)
+
+	rv, err := Project(newShelter(), []string{"root"}, fields)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"Name":   "Paws",
+		"Cats":   []interface{}{map[string]interface{}{"Name": "Fluffy"}, nil},
+		"Secret": "s",
+		"Notes":  map[string]interface{}{"Name": "Tom", "Type": "tabby"},
+	}, rv)
+
+	rv, err = Project(newShelter(), []string{"root"}, []StructField{})
+	assert.NoError(t, err)
+	assert.Empty(t, rv)
+}
+
+func Test_ProjectJSON(t *testing.T) {
+
+	rv, err := ProjectJSON(&Branch{Shelter: newShelter(), City: "Springfield"}, []string{"staff"}, nil)
+	assert.NoError(t, err)
+
+	fluffy := map[string]interface{}{"name": "", "type": ""}
+	assert.Equal(t, map[string]interface{}{
+		"city":    "Springfield",
+		"Name":    "Paws",
+		"contact": "555",
+		"cats":    []interface{}{fluffy, nil},
+		"rooms":   map[string]interface{}{"1": fluffy},
+		"code":    "",
+		"notes":   map[string]interface{}{"name": "", "type": ""},
+	}, rv)
+}
+
+func Test_Project_Errors(t *testing.T) {
+
+	_, err := Project(newShelter(), nil, nil)
+	assert.EqualError(t, err, "project: nil acl")
+
+	_, err = Project([]*Shelter{}, []string{}, nil)
+	assert.EqualError(t, err, "project: expecting struct, got []*acllibgo.Shelter")
+
+	var shelter *Shelter
+	_, err = Project(shelter, []string{}, nil)
+	assert.EqualError(t, err, "project: nil *acllibgo.Shelter")
+
+	person := &Person{Nickname: "loop"}
+	person.Father = person
+	_, err = Project(person, []string{"*"}, nil)
+	assert.EqualError(t, err, "project: cycle through *acllibgo.Person")
+}
@@ -0,0 +1,335 @@
+// Copyright 2020 Alexander Zherdev. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package acllibgo
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// RedactedPlaceholder is printed in place of the value of a denied field
+const RedactedPlaceholder = "[REDACTED]"
+
+var (
+	formatterType  = reflect.TypeOf((*fmt.Formatter)(nil)).Elem()
+	stringerType   = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+	goStringerType = reflect.TypeOf((*fmt.GoStringer)(nil)).Elem()
+	errorType      = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// RedactedValue prints a value the way fmt does with the denied fields of its structs replaced
+// See Redacted.
+type RedactedValue struct {
+	engine  *Engine
+	item    interface{}
+	subject Subject
+}
+
+// Redacted wraps the item for printing with the fmt package, denied fields are shown as RedactedPlaceholder
+// or their mask, fields in omit mode are left out. The item is not altered, the rules are evaluated
+// every time the value is printed. %v, %+v and %#v follow the fmt layout, other verbs print as %v.
+//
+//	return fmt.Errorf("cannot save %+v", acllibgo.Redacted(user, groups))
+//
+// Unlike Scrub, a denied struct field is replaced as a whole. Like fmt, nested pointers print as
+// addresses and String methods of structs with exported fields are not called.
+func Redacted(item interface{}, acl []string) RedactedValue {
+	return defaultEngine.Redacted(item, acl)
+}
+
+// RedactedSubject wraps the item for printing for the subject, see Redacted
+func RedactedSubject(item interface{}, subject Subject) RedactedValue {
+	return defaultEngine.RedactedSubject(item, subject)
+}
+
+// Redacted wraps the item for printing with the rules of the engine, see Redacted
+func (e *Engine) Redacted(item interface{}, acl []string) RedactedValue {
+	return e.RedactedSubject(item, Subject{Groups: acl})
+}
+
+// RedactedSubject wraps the item for printing for the subject with the rules of the engine, see Redacted
+func (e *Engine) RedactedSubject(item interface{}, subject Subject) RedactedValue {
+	return RedactedValue{engine: e, item: item, subject: subject}
+}
+
+// String prints the item as %v does
+func (r RedactedValue) String() string {
+	return fmt.Sprintf("%v", r)
+}
+
+// Format implements fmt.Formatter
+func (r RedactedValue) Format(f fmt.State, verb rune) {
+	subject := r.subject
+	p := &printer{
+		engine: r.engine,
+		eval:   r.engine.newEvaluation(&subject),
+		plus:   f.Flag('+'),
+		sharp:  f.Flag('#'),
+		plain:  make(map[reflect.Type]bool),
+	}
+	p.printValue(reflect.ValueOf(r.item), 0)
+	f.Write(p.buf.Bytes())
+}
+
+type printer struct {
+	buf    bytes.Buffer
+	engine *Engine
+	eval   *evaluation
+	plus   bool
+	sharp  bool
+	plain  map[reflect.Type]bool
+}
+
+// format returns the fmt format of the printer verb
+func (p *printer) format() string {
+	switch {
+	case p.sharp:
+		return "%#v"
+	case p.plus:
+		return "%+v"
+	}
+	return "%v"
+}
+
+// isPlain reports whether values of the type hold no fields the rules apply to, fmt then prints them as is
+func (p *printer) isPlain(t reflect.Type) bool {
+	if rv, found := p.plain[t]; found {
+		return rv
+	}
+	p.plain[t] = true
+
+	rv := true
+	switch t.Kind() {
+	case reflect.Interface:
+		rv = false
+	case reflect.Slice, reflect.Array:
+		rv = p.isPlain(t.Elem())
+	case reflect.Map:
+		rv = p.isPlain(t.Key()) && p.isPlain(t.Elem())
+	case reflect.Struct:
+		for x := 0; x < t.NumField() && rv; x++ {
+			f := t.Field(x)
+			rv = len(f.PkgPath) > 0 && p.isPlain(f.Type)
+		}
+		// types such as time.Time print through their methods
+		if !rv && !hasExportedFields(t) && hasPrintMethods(t) {
+			rv = true
+		}
+	}
+
+	p.plain[t] = rv
+	return rv
+}
+
+func hasExportedFields(t reflect.Type) bool {
+	for x := 0; x < t.NumField(); x++ {
+		if len(t.Field(x).PkgPath) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func hasPrintMethods(t reflect.Type) bool {
+	return t.Implements(formatterType) || t.Implements(stringerType) || t.Implements(goStringerType) || t.Implements(errorType)
+}
+
+// printValue prints the value like fmt, depth is 0 for the wrapped item
+func (p *printer) printValue(v reflect.Value, depth int) {
+	if !v.IsValid() {
+		p.buf.WriteString("<nil>")
+		return
+	}
+
+	if v.Kind() != reflect.Ptr && v.CanInterface() && p.isPlain(v.Type()) {
+		fmt.Fprintf(&p.buf, p.format(), v.Interface())
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		p.buf.WriteString(strconv.FormatBool(v.Bool()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		fmt.Fprintf(&p.buf, p.format(), v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		fmt.Fprintf(&p.buf, p.format(), v.Uint())
+	case reflect.Float32, reflect.Float64:
+		fmt.Fprintf(&p.buf, p.format(), v.Float())
+	case reflect.Complex64, reflect.Complex128:
+		fmt.Fprintf(&p.buf, p.format(), v.Complex())
+	case reflect.String:
+		fmt.Fprintf(&p.buf, p.format(), v.String())
+	case reflect.Interface:
+		if v.IsNil() {
+			if p.sharp {
+				p.buf.WriteString(v.Type().String() + "(nil)")
+			} else {
+				p.buf.WriteString("<nil>")
+			}
+			return
+		}
+		p.printValue(v.Elem(), depth+1)
+	case reflect.Struct:
+		p.printStruct(v, depth)
+	case reflect.Map:
+		p.printMap(v, depth)
+	case reflect.Slice, reflect.Array:
+		p.printList(v, depth)
+	case reflect.Ptr:
+		// like fmt, only the wrapped pointer is followed
+		if depth == 0 && !v.IsNil() {
+			switch v.Elem().Kind() {
+			case reflect.Struct, reflect.Array, reflect.Slice, reflect.Map:
+				p.buf.WriteByte('&')
+				p.printValue(v.Elem(), depth+1)
+				return
+			}
+		}
+		p.printPointer(v)
+	default:
+		p.printPointer(v)
+	}
+}
+
+func (p *printer) printPointer(v reflect.Value) {
+	address := "nil"
+	if !v.IsNil() {
+		address = "0x" + strconv.FormatUint(uint64(v.Pointer()), 16)
+	}
+
+	if p.sharp {
+		p.buf.WriteString("(" + v.Type().String() + ")(" + address + ")")
+	} else if v.IsNil() {
+		p.buf.WriteString("<nil>")
+	} else {
+		p.buf.WriteString(address)
+	}
+}
+
+func (p *printer) printStruct(v reflect.Value, depth int) {
+	info := p.eval.typeInfo(v.Type())
+	if p.sharp {
+		p.buf.WriteString(v.Type().String())
+	}
+	p.buf.WriteByte('{')
+
+	first := true
+	for i := 0; i < len(info.Field); i++ {
+		f := info.Field[i]
+		kept := !f.Exported || p.engine.allows(&info, &f, p.eval, v)
+		if !kept && f.Omit {
+			continue
+		}
+
+		if !first {
+			if p.sharp {
+				p.buf.WriteString(", ")
+			} else {
+				p.buf.WriteByte(' ')
+			}
+		}
+		first = false
+
+		if p.plus || p.sharp {
+			p.buf.WriteString(f.Name + ":")
+		}
+
+		switch {
+		case kept:
+			p.printValue(info.value(v, i), depth+1)
+		case len(f.Mask) > 0 && f.Kind == reflect.String:
+			fmt.Fprintf(&p.buf, p.format(), f.Mask)
+		default:
+			p.buf.WriteString(RedactedPlaceholder)
+		}
+	}
+
+	p.buf.WriteByte('}')
+}
+
+func (p *printer) printList(v reflect.Value, depth int) {
+	if p.sharp {
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			p.buf.WriteString(v.Type().String() + "(nil)")
+			return
+		}
+		p.buf.WriteString(v.Type().String() + "{")
+	} else {
+		p.buf.WriteByte('[')
+	}
+
+	for i := 0; i < v.Len(); i++ {
+		if i > 0 {
+			if p.sharp {
+				p.buf.WriteString(", ")
+			} else {
+				p.buf.WriteByte(' ')
+			}
+		}
+		p.printValue(v.Index(i), depth+1)
+	}
+
+	if p.sharp {
+		p.buf.WriteByte('}')
+	} else {
+		p.buf.WriteByte(']')
+	}
+}
+
+func (p *printer) printMap(v reflect.Value, depth int) {
+	if p.sharp {
+		if v.IsNil() {
+			p.buf.WriteString(v.Type().String() + "(nil)")
+			return
+		}
+		p.buf.WriteString(v.Type().String() + "{")
+	} else {
+		p.buf.WriteString("map[")
+	}
+
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return lessKey(keys[i], keys[j])
+	})
+
+	for i, key := range keys {
+		if i > 0 {
+			if p.sharp {
+				p.buf.WriteString(", ")
+			} else {
+				p.buf.WriteByte(' ')
+			}
+		}
+		p.printValue(key, depth+1)
+		p.buf.WriteByte(':')
+		p.printValue(v.MapIndex(key), depth+1)
+	}
+
+	if p.sharp {
+		p.buf.WriteByte('}')
+	} else {
+		p.buf.WriteByte(']')
+	}
+}
+
+// lessKey orders map keys like fmt for the common key kinds, others by their printed form
+func lessKey(a, b reflect.Value) bool {
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.Int() < b.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return a.Uint() < b.Uint()
+	case reflect.Float32, reflect.Float64:
+		return a.Float() < b.Float()
+	case reflect.String:
+		return a.String() < b.String()
+	case reflect.Bool:
+		return !a.Bool() && b.Bool()
+	}
+	return fmt.Sprint(a) < fmt.Sprint(b)
+}
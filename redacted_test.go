@@ -0,0 +1,104 @@
+// Copyright 2020 Alexander Zherdev. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package acllibgo
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type Credentials struct {
+	User     string
+	Password string `acl:"root"`
+	Token    string `acl:"root" aclmode:"omit"`
+	Pin      int    `acl:"root"`
+	Card     Card
+	Tags     map[string]Card
+	Expires  time.Time
+	note     string
+}
+
+type Card struct {
+	Brand  string
+	Number string `acl:"billing"`
+}
+
+// String would leak the password, Redacted does not call it
+func (c Credentials) String() string {
+	return c.User + ":" + c.Password
+}
+
+func newCredentials() Credentials {
+	return Credentials{
+		User: "john", Password: "secret", Token: "t0k", Pin: 1234,
+		Card:    Card{Brand: "visa", Number: "4111"},
+		Tags:    map[string]Card{"b": {Brand: "amex", Number: "3782"}, "a": {Brand: "mc", Number: "5500"}},
+		Expires: time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC),
+		note:    "n",
+	}
+}
+
+func Test_Redacted(t *testing.T) {
+
+	c := newCredentials()
+
+	assert.Equal(t, "{john [REDACTED] [REDACTED] {visa [REDACTED]} map[a:{mc [REDACTED]} b:{amex [REDACTED]}] 2020-06-01 00:00:00 +0000 UTC n}",
+		fmt.Sprintf("%v", Redacted(c, []string{"user"})))
+	assert.Equal(t, "&{User:john Password:[REDACTED] Pin:[REDACTED] Card:{Brand:visa Number:[REDACTED]} Tags:map[a:{Brand:mc Number:[REDACTED]} b:{Brand:amex Number:[REDACTED]}] Expires:2020-06-01 00:00:00 +0000 UTC note:n}",
+		fmt.Sprintf("%+v", Redacted(&c, []string{"user"})))
+	assert.Equal(t, `acllibgo.Credentials{User:"john", Password:"secret", Token:"t0k", Pin:1234, Card:acllibgo.Card{Brand:"visa", Number:[REDACTED]}, `+
+		`Tags:map[string]acllibgo.Card{"a":acllibgo.Card{Brand:"mc", Number:[REDACTED]}, "b":acllibgo.Card{Brand:"amex", Number:[REDACTED]}}, `+
+		`Expires:time.Date(2020, time.June, 1, 0, 0, 0, 0, time.UTC), note:"n"}`,
+		fmt.Sprintf("%#v", Redacted(c, []string{"root"})))
+	assert.Equal(t, "{john [REDACTED] [REDACTED] {visa 4111} map[a:{mc 5500} b:{amex 3782}] 2020-06-01 00:00:00 +0000 UTC n}",
+		Redacted(c, []string{"billing"}).String())
+
+	assert.Equal(t, newCredentials(), c)
+}
+
+func Test_Redacted_Values(t *testing.T) {
+
+	c := newCredentials()
+	list := []*Credentials{&c}
+	var nilCard *Card
+
+	assert.Equal(t, fmt.Sprintf("%v", list), Redacted(list, nil).String())
+	assert.Equal(t, "<nil>", Redacted(nilCard, nil).String())
+	assert.Equal(t, "(*acllibgo.Card)(nil)", fmt.Sprintf("%#v", Redacted(nilCard, nil)))
+	assert.Equal(t, "<nil>", Redacted(nil, nil).String())
+	assert.Equal(t, "42", Redacted(42, nil).String())
+	assert.Equal(t, "[{visa [REDACTED]} {amex [REDACTED]}]", Redacted([]Card{c.Card, c.Tags["b"]}, nil).String())
+	assert.Equal(t, "[{visa [REDACTED]} <nil>]", Redacted([]interface{}{c.Card, nil}, nil).String())
+
+	err := fmt.Errorf("cannot save %v", Redacted(c.Card, []string{}))
+	assert.Equal(t, errors.New("cannot save {visa [REDACTED]}"), err)
+}
+
+func Test_Redacted_Engine(t *testing.T) {
+
+	e := NewEngine(Options{DenyUntagged: true})
+	card := Card{Brand: "visa", Number: "4111"}
+	assert.Equal(t, "{[REDACTED] [REDACTED]}", e.Redacted(card, []string{}).String())
+	assert.Equal(t, "{[REDACTED] 4111}", e.RedactedSubject(card, Subject{Groups: []string{"billing"}}).String())
+	assert.Equal(t, "{visa [REDACTED]}", RedactedSubject(card, Subject{Anonymous: true}).String())
+
+	e = newPolicyEngine(t, `{"types": {"github.com/mralexzee/acllibgo.GenUser": {"fields": {"Email": {"acl": "admin", "mask": "***"}, "Token": {"mode": "omit"}}}}}`)
+	user := newGenUser()
+	assert.Equal(t, `&acllibgo.GenUser{ID:"u1", Name:"John", Email:"***", Address:(*acllibgo.GenAddress)(`+fmt.Sprintf("%p", user.Address)+`)}`,
+		fmt.Sprintf("%#v", e.Redacted(&user, []string{"user"})))
+}
+
+func Benchmark_Redacted(b *testing.B) {
+	c := newCredentials()
+	groups := []string{"user"}
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		_ = fmt.Sprintf("%+v", Redacted(&c, groups))
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright 2020 Alexander Zherdev. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package acllibgo
+
+import (
+	"os"
+	"reflect"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// policyState is an immutable policy version together with the per-type plans compiled for it
+// Swapping the state drops every compiled plan, calls in flight keep the state they started with.
+type policyState struct {
+	policy   *Policy
+	version  string
+	rules    map[reflect.Type]*typeRules
+	groups   *groupRegistry
+	adapters []Adapter
+	plans    sync.Map
+}
+
+func newPolicyState(groups *groupRegistry, adapters []Adapter, p *Policy, version string, rules map[reflect.Type]*typeRules) *policyState {
+	if rules == nil {
+		rules = make(map[reflect.Type]*typeRules)
+	}
+	return &policyState{policy: p, version: version, rules: rules, groups: groups, adapters: adapters}
+}
+
+// plan returns the type information with the policy rules applied, compiled once per type
+// The type information of a type accepted by an adapter is built from the adapter fields instead.
+func (s *policyState) plan(t reflect.Type) typeInfo {
+	if plan, ok := s.plans.Load(t); ok {
+		return plan.(typeInfo)
+	}
+
+	var plan typeInfo
+	if adapter := s.adapter(t); adapter != nil {
+		plan = adaptedTypeInfo(t, adapter)
+	} else if rules := s.rules[t]; rules != nil {
+		plan = rules.apply(getTypeInfo(t))
+	} else {
+		plan = getTypeInfo(t)
+		plan.Field = append([]fieldInfo(nil), plan.Field...)
+	}
+	for x := range plan.Field {
+		s.groups.compile(&plan.Field[x])
+	}
+	resolveConditions(plan)
+	s.plans.Store(t, plan)
+
+	return plan
+}
+
+func (e *Engine) currentState() *policyState {
+	return e.state.Load().(*policyState)
+}
+
+// RegisterTypes makes the struct types, and the struct types reachable from their fields, known to policies
+func (e *Engine) RegisterTypes(types ...reflect.Type) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	for _, t := range types {
+		collectTypes(t, e.types)
+	}
+}
+
+// SetPolicy validates the policy against the registered types and atomically swaps it in
+// Every type and field the policy refers to must exist, otherwise the policy is rejected and the
+// current one stays in place. A nil policy removes the current one. Calls in flight finish with the
+// policy they started with, the compiled per-type plans of the previous policy are discarded.
+func (e *Engine) SetPolicy(p *Policy) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return e.setPolicyLocked(p)
+}
+
+// MergePolicy merges the policy into the active one, see Policy.Merge, and swaps the result in
+func (e *Engine) MergePolicy(p *Policy) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return e.setPolicyLocked(e.currentState().policy.Merge(p))
+}
+
+func (e *Engine) setPolicyLocked(p *Policy) error {
+	var rules map[reflect.Type]*typeRules
+	if p != nil {
+		compiled, err := compilePolicy(p, e.types)
+		if err != nil {
+			return err
+		}
+		rules = compiled
+	}
+
+	e.sequence++
+	version := strconv.FormatUint(e.sequence, 10)
+	if p != nil && len(p.Version) > 0 {
+		version = p.Version
+	}
+
+	e.state.Store(newPolicyState(e.groups, e.currentState().adapters, p, version, rules))
+
+	return nil
+}
+
+// Policy returns the active policy, nil when there is none
+func (e *Engine) Policy() *Policy {
+	return e.currentState().policy
+}
+
+// PolicyVersion returns the version of the active policy
+// The version is the Policy.Version or, when the policy has none, the number of policies set so far.
+// The engine starts with version "".
+func (e *Engine) PolicyVersion() string {
+	return e.currentState().version
+}
+
+// WatchPolicyFile loads the policy file and reloads it every time it changes on disk
+// The file is checked every interval. A change that fails to load or validate is reported to
+// onError, which may be nil, and the active policy stays in place. Call stop to end the watch.
+func (e *Engine) WatchPolicyFile(path string, interval time.Duration, onError func(error)) (stop func(), err error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := LoadPolicyFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err = e.SetPolicy(p); err != nil {
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	var stopped int32
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		modified, size := info.ModTime(), info.Size()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			info, err := os.Stat(path)
+			if err != nil {
+				reportError(onError, err)
+				continue
+			}
+			if info.ModTime().Equal(modified) && info.Size() == size {
+				continue
+			}
+			modified, size = info.ModTime(), info.Size()
+
+			p, err := LoadPolicyFile(path)
+			if err == nil {
+				err = e.SetPolicy(p)
+			}
+			reportError(onError, err)
+		}
+	}()
+
+	return func() {
+		if atomic.CompareAndSwapInt32(&stopped, 0, 1) {
+			close(done)
+		}
+	}, nil
+}
+
+func reportError(onError func(error), err error) {
+	if err != nil && onError != nil {
+		onError(err)
+	}
+}
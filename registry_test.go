@@ -0,0 +1,136 @@
+// Copyright 2020 Alexander Zherdev. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package acllibgo
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const emailAdminPolicy = `{"version": "v2", "types": {"github.com/mralexzee/acllibgo.GenUser": {"fields": {"Email": {"acl": "admin"}}}}}`
+
+func Test_Registry_Version(t *testing.T) {
+
+	e := NewEngine(Options{})
+	e.RegisterTypes(reflect.TypeOf(GenUser{}))
+	assert.Equal(t, "", e.PolicyVersion())
+
+	p, err := ParsePolicy([]byte(genPolicy))
+	assert.NoError(t, err)
+	assert.NoError(t, e.SetPolicy(p))
+	assert.Equal(t, "v1", e.PolicyVersion())
+	assert.Equal(t, p, e.Policy())
+
+	// without a version the sequence number is used
+	assert.NoError(t, e.SetPolicy(&Policy{}))
+	assert.Equal(t, "2", e.PolicyVersion())
+
+	// rejected policy leaves the active one in place
+	assert.Error(t, e.SetPolicy(&Policy{Version: "bad", Types: map[string]TypePolicy{"missing.Type": {}}}))
+	assert.Equal(t, "2", e.PolicyVersion())
+}
+
+func Test_Registry_SwapInvalidatesPlans(t *testing.T) {
+
+	e := NewEngine(Options{})
+	e.RegisterTypes(reflect.TypeOf(GenUser{}))
+
+	testItem := newGenUser()
+	assert.NoError(t, e.Scrub(&testItem, []string{"user"}))
+	assert.Equal(t, "john@example.com", testItem.Email)
+
+	p, err := ParsePolicy([]byte(emailAdminPolicy))
+	assert.NoError(t, err)
+	assert.NoError(t, e.SetPolicy(p))
+
+	testItem = newGenUser()
+	assert.NoError(t, e.Scrub(&testItem, []string{"user"}))
+	assert.Equal(t, "", testItem.Email)
+
+	assert.NoError(t, e.SetPolicy(nil))
+
+	testItem = newGenUser()
+	assert.NoError(t, e.Scrub(&testItem, []string{"user"}))
+	assert.Equal(t, "john@example.com", testItem.Email)
+}
+
+func Test_Registry_ConcurrentSwap(t *testing.T) {
+
+	e := NewEngine(Options{})
+	e.RegisterTypes(reflect.TypeOf(GenUser{}))
+	p, err := ParsePolicy([]byte(emailAdminPolicy))
+	assert.NoError(t, err)
+
+	wg := sync.WaitGroup{}
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := 0; n < 200; n++ {
+				testItem := newGenUser()
+				assert.NoError(t, e.Scrub(&testItem, []string{"user"}))
+				assert.Contains(t, []string{"", "john@example.com"}, testItem.Email)
+			}
+		}()
+	}
+	for n := 0; n < 50; n++ {
+		if n%2 == 0 {
+			assert.NoError(t, e.SetPolicy(p))
+		} else {
+			assert.NoError(t, e.SetPolicy(nil))
+		}
+	}
+	wg.Wait()
+}
+
+func Test_Registry_WatchPolicyFile(t *testing.T) {
+
+	f, err := ioutil.TempFile("", "policy-*.json")
+	assert.NoError(t, err)
+	defer os.Remove(f.Name())
+	f.WriteString(genPolicy)
+	f.Close()
+
+	e := NewEngine(Options{})
+	e.RegisterTypes(reflect.TypeOf(GenUser{}))
+
+	errs := make(chan error, 10)
+	stop, err := e.WatchPolicyFile(f.Name(), 5*time.Millisecond, func(err error) { errs <- err })
+	assert.NoError(t, err)
+	defer stop()
+	assert.Equal(t, "v1", e.PolicyVersion())
+
+	// invalid update is reported and ignored
+	assert.NoError(t, ioutil.WriteFile(f.Name(), []byte(`{"types": {"missing.Type": {}}}`), 0600))
+	os.Chtimes(f.Name(), time.Now(), time.Now().Add(time.Second))
+	select {
+	case err := <-errs:
+		assert.Error(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected reload error")
+	}
+	assert.Equal(t, "v1", e.PolicyVersion())
+
+	// valid update is swapped in
+	assert.NoError(t, ioutil.WriteFile(f.Name(), []byte(emailAdminPolicy), 0600))
+	os.Chtimes(f.Name(), time.Now(), time.Now().Add(2*time.Second))
+	deadline := time.Now().Add(2 * time.Second)
+	for e.PolicyVersion() != "v2" && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	assert.Equal(t, "v2", e.PolicyVersion())
+
+	stop()
+	stop()
+
+	_, err = e.WatchPolicyFile(f.Name()+".missing", time.Second, nil)
+	assert.Error(t, err)
+}
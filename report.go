@@ -0,0 +1,86 @@
+// Copyright 2020 Alexander Zherdev. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package acllibgo
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// Action is the redaction applied to a field
+type Action string
+
+const (
+	// ActionZero sets the field to its default value
+	ActionZero Action = "zero"
+	// ActionMask replaces the value of a string field with the mask set by a policy
+	ActionMask Action = "mask"
+)
+
+const (
+	// RuleKeep is reported by Keep for fields missing from the kept fields
+	RuleKeep string = "keep"
+	// RuleZero is reported by Zero for fields listed in the zeroed fields
+	RuleZero string = "zero"
+)
+
+// Redaction describes a single field cleared by Scrub, Keep or Zero
+// Path is the location of the field from the item passed in, e.g. Children[0].Height or Friends[best].Nickname
+type Redaction struct {
+	Path   string `json:"path"`
+	Rule   string `json:"rule"`
+	Action Action `json:"action"`
+}
+
+// Observer receives a Redaction for every cleared field
+// Observers are called synchronously during the traversal.
+type Observer interface {
+	Redacted(r Redaction)
+}
+
+// ObserverFunc adapts an ordinary function to the Observer interface
+type ObserverFunc func(r Redaction)
+
+// Redacted calls f(r)
+func (f ObserverFunc) Redacted(r Redaction) {
+	f(r)
+}
+
+// Report is an Observer collecting redactions in traversal order
+type Report struct {
+	Redactions []Redaction `json:"redactions"`
+}
+
+// Redacted appends r to the report
+func (report *Report) Redacted(r Redaction) {
+	report.Redactions = append(report.Redactions, r)
+}
+
+// paths are only built when tracked, keeping the unobserved traversal free of allocations
+
+func fieldPath(track bool, path string, name string) string {
+	if !track {
+		return ""
+	}
+	if len(path) == 0 {
+		return name
+	}
+	return path + "." + name
+}
+
+func indexPath(track bool, path string, i int) string {
+	if !track {
+		return ""
+	}
+	return path + "[" + strconv.Itoa(i) + "]"
+}
+
+func keyPath(track bool, path string, key reflect.Value) string {
+	if !track {
+		return ""
+	}
+	return path + "[" + fmt.Sprint(key.Interface()) + "]"
+}
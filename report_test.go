@@ -0,0 +1,87 @@
+// Copyright 2020 Alexander Zherdev. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package acllibgo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_ScrubWithReport_Basic(t *testing.T) {
+
+	testItem := newPerson()
+
+	report, err := ScrubWithReport(&testItem, []string{"tester"})
+	assert.NoError(t, err)
+	assert.Nil(t, testItem.Groups)
+
+	assert.Contains(t, report, Redaction{Path: "Groups", Rule: `acl:"admin"`, Action: ActionZero})
+	assert.Contains(t, report, Redaction{Path: "Children[0].Groups", Rule: `acl:"admin"`, Action: ActionZero})
+	assert.Contains(t, report, Redaction{Path: "Friends[best].Groups", Rule: `acl:"admin"`, Action: ActionZero})
+	assert.Contains(t, report, Redaction{Path: "Mother.Groups", Rule: `acl:"admin"`, Action: ActionZero})
+	for _, r := range report {
+		assert.NotEqual(t, "Height", r.Path)
+	}
+}
+
+func Test_ScrubWithReport_Error(t *testing.T) {
+
+	testItem := newPerson()
+
+	report, err := ScrubWithReport(testItem, []string{"tester"})
+	assert.Error(t, err)
+	assert.Empty(t, report)
+}
+
+func Test_ScrubWithObserver_Stream(t *testing.T) {
+
+	one := newPerson()
+	two := newPerson()
+
+	paths := []string{}
+	err := ScrubWithObserver([]*Person{&one, &two}, []string{"tester"}, ObserverFunc(func(r Redaction) {
+		paths = append(paths, r.Path)
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, "[0].Groups", paths[0])
+	assert.Contains(t, paths, "[1].Father.Groups")
+	assert.NotContains(t, paths, "[0].Height")
+}
+
+func Test_KeepWithObserver_Basic(t *testing.T) {
+
+	testItem := newPerson()
+
+	report := &Report{}
+	err := KeepWithObserver(&testItem, []StructField{{Name: "Age"}, {Name: "Children", Fields: []StructField{{Name: "Age"}}}}, report)
+	assert.NoError(t, err)
+	assert.Contains(t, report.Redactions, Redaction{Path: "Height", Rule: RuleKeep, Action: ActionZero})
+	assert.Contains(t, report.Redactions, Redaction{Path: "Children[1].Nickname", Rule: RuleKeep, Action: ActionZero})
+	assert.NotContains(t, report.Redactions, Redaction{Path: "Age", Rule: RuleKeep, Action: ActionZero})
+}
+
+func Test_ZeroWithObserver_Basic(t *testing.T) {
+
+	testItem := newPerson()
+
+	report := &Report{}
+	err := ZeroWithObserver(&testItem, []StructField{{Name: "Nickname"}, {Name: "Children", Fields: []StructField{{Name: "Age"}}}}, report)
+	assert.NoError(t, err)
+	assert.Equal(t, []Redaction{
+		{Path: "Nickname", Rule: RuleZero, Action: ActionZero},
+		{Path: "Children[0].Age", Rule: RuleZero, Action: ActionZero},
+		{Path: "Children[1].Age", Rule: RuleZero, Action: ActionZero},
+	}, report.Redactions)
+}
+
+func Benchmark_Scrub_Observer(b *testing.B) {
+	testItem := newPerson()
+	report := &Report{}
+	for n := 0; n < b.N; n++ {
+		report.Redactions = report.Redactions[:0]
+		ScrubWithObserver(&testItem, []string{"access", "login"}, report)
+	}
+}
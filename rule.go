@@ -0,0 +1,98 @@
+// Copyright 2020 Alexander Zherdev. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package acllibgo
+
+import (
+	"strings"
+)
+
+// aclTerm is a single alternative of an 'acl' tag
+// A term is a group name (admin), a keyword (*, public, self, authenticated, anonymous), a predicate reference (@sameTenant)
+// or a group restricted by a predicate (admin@sameTenant) or by a condition (analyst@after(release_at)). Groups may be patterns (org:*:billing:*),
+// compiled once when the tag is parsed.
+type aclTerm struct {
+	Group     string
+	Predicate string
+	Pattern   *groupPattern
+	Condition *aclCondition
+	Keyword   aclKeywords
+}
+
+// aclKeywords flags the keyword terms of an 'acl' rule, compiled into the plan of the field
+type aclKeywords uint8
+
+const (
+	keywordPublic aclKeywords = 1 << iota
+	keywordAny
+	keywordAuthenticated
+	keywordAnonymous
+	keywordSelf
+)
+
+// keyword returns the keyword flag of the term, zero when the term is not a keyword
+func (t aclTerm) keyword() aclKeywords {
+	switch {
+	case len(t.Predicate) > 0:
+		return 0
+	case t.Group == aclAny:
+		return keywordAny
+	case t.is(aclPublic):
+		return keywordPublic
+	case t.is(aclAuthenticated):
+		return keywordAuthenticated
+	case t.is(aclAnonymous):
+		return keywordAnonymous
+	case t.is(aclSelf):
+		return keywordSelf
+	}
+	return 0
+}
+
+// parseAcl splits the 'acl' tag text into terms, alternatives are separated by ',' or '|'
+func parseAcl(text string) []aclTerm {
+	parts := strings.FieldsFunc(text, func(r rune) bool {
+		return r == ',' || r == '|'
+	})
+
+	rv := make([]aclTerm, 0, len(parts))
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		if len(part) == 0 {
+			continue
+		}
+
+		term := aclTerm{Group: part}
+		if at := strings.IndexByte(part, '@'); at >= 0 {
+			term.Group = strings.TrimSpace(part[:at])
+			term.Predicate = strings.TrimSpace(part[at+1:])
+		}
+		if condition, ok := parseCondition(term.Predicate); ok {
+			term.Condition = &condition
+		}
+		if isGroupPattern(term.Group) {
+			term.Pattern = compileGroupPattern(term.Group)
+		}
+		term.Keyword = term.keyword()
+
+		rv = append(rv, term)
+	}
+
+	return rv
+}
+
+// isGroup reports whether the term is a plain group name rather than a keyword, a pattern or a predicate
+func (t aclTerm) isGroup() bool {
+	return len(t.Predicate) == 0 && len(t.Group) > 0 && t.Pattern == nil && t.Keyword == 0
+}
+
+// isRestrictedPredicate reports whether the term is a predicate restricted to a group, group@name
+func (t aclTerm) isRestrictedPredicate() bool {
+	return len(t.Predicate) > 0 && len(t.Group) > 0 && t.Pattern == nil
+}
+
+// is reports whether the term is the plain keyword
+func (t aclTerm) is(keyword string) bool {
+	return len(t.Predicate) == 0 && strings.EqualFold(t.Group, keyword)
+}
@@ -0,0 +1,212 @@
+// Copyright 2020 Alexander Zherdev. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package acllibgo
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// Schema is the subset of a JSON Schema / OpenAPI schema object needed to describe Go types
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Definitions          map[string]*Schema `json:"definitions,omitempty"`
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// JSONSchema renders the JSON Schema of the type as seen by a caller with the provided groups
+// Fields Scrub would clear for the groups are left out of the properties. Structs Scrub descends
+// into are emitted once under "definitions" and referenced with "$ref".
+func JSONSchema(itemType reflect.Type, groups []string) *Schema {
+	return defaultEngine.JSONSchema(itemType, groups)
+}
+
+// JSONSchema renders the JSON Schema of the type as seen by a caller of Engine.Scrub with the provided groups
+func (e *Engine) JSONSchema(itemType reflect.Type, groups []string) *Schema {
+	b := newSchemaBuilder(e, groups, "#/definitions/", "")
+	rv := b.typeSchema(itemType, true)
+	if len(b.defs) > 0 {
+		rv.Definitions = b.defs
+	}
+
+	return rv
+}
+
+// OpenAPIComponents renders one OpenAPI component schema per struct type and view
+// Views map a view name (e.g. "admin", "user") to the groups of that caller. Components are
+// named <TypeName>_<view> and reference each other under "#/components/schemas/".
+func OpenAPIComponents(itemType reflect.Type, views map[string][]string) map[string]*Schema {
+	return defaultEngine.OpenAPIComponents(itemType, views)
+}
+
+// OpenAPIComponents renders one OpenAPI component schema per struct type and view as seen by Engine.Scrub
+func (e *Engine) OpenAPIComponents(itemType reflect.Type, views map[string][]string) map[string]*Schema {
+	rv := make(map[string]*Schema)
+	for view, groups := range views {
+		b := newSchemaBuilder(e, groups, "#/components/schemas/", "_"+view)
+		b.typeSchema(itemType, true)
+		for name, s := range b.defs {
+			rv[name] = s
+		}
+	}
+
+	return rv
+}
+
+type schemaBuilder struct {
+	engine    *Engine
+	eval      *evaluation
+	refPrefix string
+	suffix    string
+	defs      map[string]*Schema
+	names     map[reflect.Type]string
+	expanding map[reflect.Type]bool
+}
+
+func newSchemaBuilder(engine *Engine, groups []string, refPrefix string, suffix string) *schemaBuilder {
+	return &schemaBuilder{
+		engine:    engine,
+		eval:      engine.newEvaluation(&Subject{Groups: groups}),
+		refPrefix: refPrefix,
+		suffix:    suffix,
+		defs:      make(map[string]*Schema),
+		names:     make(map[reflect.Type]string),
+		expanding: make(map[reflect.Type]bool),
+	}
+}
+
+// typeSchema returns the schema of t, scrubbed tells whether Scrub applies ACLs to a struct at this position
+func (b *schemaBuilder) typeSchema(t reflect.Type, scrubbed bool) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t.Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(jsonMarshalerType):
+		return &Schema{}
+	case t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType):
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uintptr:
+		return &Schema{Type: "integer"}
+	case reflect.Int32, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Ptr:
+		return b.typeSchema(t.Elem(), scrubbed && t.Elem().Kind() == reflect.Struct)
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: b.typeSchema(t.Elem(), scrubbed && t.Elem().Kind() == reflect.Ptr)}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: b.typeSchema(t.Elem(), scrubbed && t.Elem().Kind() == reflect.Ptr)}
+	case reflect.Struct:
+		if !scrubbed || len(t.Name()) == 0 {
+			if b.expanding[t] {
+				return &Schema{Type: "object"}
+			}
+			return b.structSchema(t, scrubbed)
+		}
+		return b.structRef(t)
+	}
+
+	return &Schema{}
+}
+
+// structRef registers the scrubbed struct under definitions and returns a reference to it
+func (b *schemaBuilder) structRef(t reflect.Type) *Schema {
+	name, ok := b.names[t]
+	if !ok {
+		name = t.Name() + b.suffix
+		for i := 2; b.defs[name] != nil; i++ {
+			name = t.Name() + strconv.Itoa(i) + b.suffix
+		}
+		b.names[t] = name
+
+		// register before expanding so recursive references resolve to the same definition
+		b.defs[name] = &Schema{}
+		*b.defs[name] = *b.structSchema(t, true)
+	}
+
+	return &Schema{Ref: b.refPrefix + name}
+}
+
+func (b *schemaBuilder) structSchema(t reflect.Type, scrubbed bool) *Schema {
+	b.expanding[t] = true
+	defer delete(b.expanding, t)
+
+	rv := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+
+	typeInfo := b.eval.typeInfo(t)
+	for _, f := range typeInfo.Field {
+		if !f.Exported || f.JsonName == "-" {
+			continue
+		}
+		if scrubbed && !b.engine.allows(&typeInfo, &f, b.eval, reflect.Value{}) {
+			continue
+		}
+
+		_, nested := scrubbedElem(f.Type)
+		fieldSchema := b.typeSchema(f.Type, scrubbed && nested)
+
+		// encoding/json promotes the fields of embedded structs without an explicit name
+		if f.Anonymous && f.JsonName == f.Name {
+			if embedded, ok := b.resolve(fieldSchema); ok && embedded.Type == "object" {
+				for name, s := range embedded.Properties {
+					if _, found := rv.Properties[name]; !found {
+						rv.Properties[name] = s
+					}
+				}
+				continue
+			}
+		}
+
+		rv.Properties[f.JsonName] = fieldSchema
+	}
+
+	return rv
+}
+
+// resolve follows a definitions reference created by this builder
+func (b *schemaBuilder) resolve(s *Schema) (*Schema, bool) {
+	if len(s.Ref) == 0 {
+		return s, true
+	}
+
+	for name, def := range b.defs {
+		if b.refPrefix+name == s.Ref {
+			return def, true
+		}
+	}
+
+	return nil, false
+}
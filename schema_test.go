@@ -0,0 +1,67 @@
+// Copyright 2020 Alexander Zherdev. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package acllibgo
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_JSONSchema_Basic(t *testing.T) {
+
+	s := JSONSchema(reflect.TypeOf(Person{}), []string{"tester"})
+
+	assert.Equal(t, "#/definitions/Person", s.Ref)
+	person := s.Definitions["Person"]
+	assert.NotNil(t, person)
+	assert.Equal(t, "object", person.Type)
+	assert.Equal(t, "integer", person.Properties["height"].Type)
+	assert.Equal(t, "int32", person.Properties["height"].Format)
+	assert.Equal(t, "#/definitions/Person", person.Properties["mother"].Ref)
+	assert.Equal(t, "array", person.Properties["children"].Type)
+	assert.Equal(t, "#/definitions/Person", person.Properties["children"].Items.Ref)
+	assert.Equal(t, "#/definitions/Person", person.Properties["friends"].AdditionalProperties.Ref)
+	assert.Equal(t, "date-time", person.Properties["created"].Format)
+
+	// value structs are not scrubbed, all their fields are present
+	assert.Contains(t, person.Properties["petCat"].Properties, "name")
+	assert.Contains(t, person.Properties["petCat"].Properties, "type")
+
+	_, found := person.Properties["groups"]
+	assert.False(t, found)
+
+	_, err := json.Marshal(s)
+	assert.NoError(t, err)
+}
+
+func Test_JSONSchema_NoGroups(t *testing.T) {
+
+	s := JSONSchema(reflect.TypeOf(&Person{}), []string{})
+	person := s.Definitions["Person"]
+
+	for _, name := range []string{"age", "father", "children", "friends", "created"} {
+		assert.Contains(t, person.Properties, name)
+	}
+	for _, name := range []string{"height", "groups", "fullName", "nickname", "mother", "petCat", "birthdate"} {
+		assert.NotContains(t, person.Properties, name)
+	}
+}
+
+func Test_OpenAPIComponents_Views(t *testing.T) {
+
+	components := OpenAPIComponents(reflect.TypeOf([]*Person{}), map[string][]string{
+		"admin": {"admin", "tester"},
+		"user":  {"user"},
+	})
+
+	assert.Len(t, components, 2)
+	assert.Contains(t, components["Person_admin"].Properties, "groups")
+	assert.Equal(t, "#/components/schemas/Person_admin", components["Person_admin"].Properties["mother"].Ref)
+	assert.NotContains(t, components["Person_user"].Properties, "groups")
+	assert.Contains(t, components["Person_user"].Properties, "nickname")
+}
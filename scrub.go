@@ -7,23 +7,114 @@ package acllibgo
 import (
 	"errors"
 	"reflect"
-	"strings"
 )
 
 // Scrub sets structure's fields to default value based on optional 'acl' field tag
 // Tag 'acl' on a field has the following effect on Scrub:
-//  - <not defined> : Field is not altered
-//  - acl:"" : Field is not altered
-//  - acl:"*" : Field is not altered as long as Scrub acl has some value
-//  - acl:admin : Field is not altered as long as Scrub acl has an array containing "admin" element
-//  - acl:admin,user : Field is not altered as long as Scrub acl has an array containing "admin" or "user" element
+//   - <not defined> : Field is not altered
+//   - acl:"" : Field is not altered
+//   - acl:"*" : Field is not altered as long as Scrub acl has at least one group
+//   - acl:authenticated : Field is not altered for any authenticated caller, with or without groups, see ScrubAuthenticated
+//   - acl:anonymous : Field is not altered for anonymous callers only, see ScrubAnonymous
+//   - acl:admin : Field is not altered as long as Scrub acl has an array containing "admin" element
+//   - acl:admin,user : Field is not altered as long as Scrub acl has an array containing "admin" or "user" element
+//   - acl:org:*:billing:** : Field is not altered for a group matching the pattern, e.g. "org:acme:billing:read"
+//   - acl:public : Field is never altered, opts the field in when the engine denies untagged fields
+//   - acl:admin,self : Field is not altered for "admin" or for the owner of the struct, see ScrubSubject
+//   - acl:@sameTenant|admin : Field is not altered for "admin" or when predicate "sameTenant" holds, see RegisterPredicate
+//   - acl:analyst@after(release_at) : Field is not altered for "analyst" once the subject clock reaches the time in
+//     the sibling field release_at (json or Go name), before(field) is the opposite and flag(name) checks Subject.Flags
+//
+// A blank marker field _ struct{} `acl:"admin"` sets the default 'acl' tag of the struct, inherited by
+// every field without a tag of its own. A field overrides the default with its own tag, acl:public included.
+//
+// Groups are namespaced with ':'. In patterns '*' matches one segment and '**' any number of segments,
+// both in tags and in the provided groups ("org:acme:**" matches a field tagged "org:acme:billing"). Provided
+// patterns are matched against the groups of the tag, never against its patterns.
+// An exact group match takes precedence over the most specific pattern, which takes precedence over acl:"*".
 func Scrub(item interface{}, acl []string) error {
-	if item == nil {
-		return errors.New("scrub: nil item")
+	return defaultEngine.Scrub(item, acl)
+}
+
+// ScrubAnonymous scrubs the item for a caller that is not logged in
+// Only untagged fields and the fields tagged 'public' or 'anonymous' are kept.
+func ScrubAnonymous(item interface{}) error {
+	return defaultEngine.ScrubAnonymous(item)
+}
+
+// ScrubAuthenticated scrubs the item for a logged in caller with the groups, which may be nil or empty
+// Scrub treats its acl the same way except a nil acl is an error there.
+func ScrubAuthenticated(item interface{}, groups []string) error {
+	return defaultEngine.ScrubAuthenticated(item, groups)
+}
+
+// ScrubWithObserver scrubs the item like Scrub and reports every cleared field to the observer
+func ScrubWithObserver(item interface{}, acl []string, observer Observer) error {
+	return defaultEngine.ScrubWithObserver(item, acl, observer)
+}
+
+// ScrubWithReport scrubs the item like Scrub and returns the list of cleared fields
+func ScrubWithReport(item interface{}, acl []string) ([]Redaction, error) {
+	return defaultEngine.ScrubWithReport(item, acl)
+}
+
+// ScrubSubject scrubs the item like Scrub for the subject's groups and evaluates principal-relative rules
+// The 'self' rule keeps the field when the value of the struct's owner field, the field tagged with
+// 'aclowner', equals the subject ID. The owner is evaluated for every struct as the traversal descends.
+//
+//	type Profile struct {
+//		UserID string `aclowner:""`
+//		Email  string `acl:"admin,self"`
+//	}
+func ScrubSubject(item interface{}, subject Subject) error {
+	return defaultEngine.ScrubSubject(item, subject)
+}
+
+// Scrub sets structure's fields to default value based on 'acl' field tag and the engine options
+func (e *Engine) Scrub(item interface{}, acl []string) error {
+	if acl == nil {
+		return errors.New("scrub: nil acl")
 	}
+	return e.scrub(item, e.newEvaluation(&Subject{Groups: acl}), nil, "")
+}
+
+// ScrubAnonymous scrubs the item like Engine.Scrub for a caller that is not logged in
+func (e *Engine) ScrubAnonymous(item interface{}) error {
+	return e.scrub(item, e.newEvaluation(&Subject{Anonymous: true}), nil, "")
+}
+
+// ScrubAuthenticated scrubs the item like Engine.Scrub for a logged in caller with the groups
+func (e *Engine) ScrubAuthenticated(item interface{}, groups []string) error {
+	return e.scrub(item, e.newEvaluation(&Subject{Groups: groups}), nil, "")
+}
+
+// ScrubSubject scrubs the item like Engine.Scrub for the subject
+func (e *Engine) ScrubSubject(item interface{}, subject Subject) error {
+	return e.scrub(item, e.newEvaluation(&subject), nil, "")
+}
+
+// ScrubWithObserver scrubs the item like Engine.Scrub and reports every cleared field to the observer
+func (e *Engine) ScrubWithObserver(item interface{}, acl []string, observer Observer) error {
 	if acl == nil {
 		return errors.New("scrub: nil acl")
 	}
+	return e.scrub(item, e.newEvaluation(&Subject{Groups: acl}), observer, "")
+}
+
+// ScrubWithReport scrubs the item like Engine.Scrub and returns the list of cleared fields
+func (e *Engine) ScrubWithReport(item interface{}, acl []string) ([]Redaction, error) {
+	if acl == nil {
+		return []Redaction{}, errors.New("scrub: nil acl")
+	}
+	report := &Report{Redactions: []Redaction{}}
+	err := e.scrub(item, e.newEvaluation(&Subject{Groups: acl}), report, "")
+	return report.Redactions, err
+}
+
+func (e *Engine) scrub(item interface{}, eval *evaluation, observer Observer, path string) error {
+	if item == nil {
+		return errors.New("scrub: nil item")
+	}
 
 	itemValue := reflect.ValueOf(item)
 	if !itemValue.IsValid() {
@@ -40,7 +131,7 @@ func Scrub(item interface{}, acl []string) error {
 
 		for i := 0; i < itemValue.Len(); i++ {
 			item := itemValue.Index(i)
-			Scrub(item.Interface(), acl)
+			e.scrub(item.Interface(), eval, observer, indexPath(observer != nil, path, i))
 		}
 
 		return nil
@@ -53,7 +144,7 @@ func Scrub(item interface{}, acl []string) error {
 		for _, mKey := range itemValue.MapKeys() {
 			mapValue := itemValue.MapIndex(mKey)
 			if mapValue.Kind() == reflect.Ptr && !mapValue.IsNil() {
-				Scrub(mapValue.Interface(), acl)
+				e.scrub(mapValue.Interface(), eval, observer, keyPath(observer != nil, path, mKey))
 			}
 		}
 
@@ -72,43 +163,53 @@ func Scrub(item interface{}, acl []string) error {
 		return nil
 	}
 
-	elemTypeInfo := getTypeInfo(elemValue.Type())
+	elemTypeInfo := eval.typeInfo(elemValue.Type())
 
 	// Ensure we have a struct
-	if elemTypeInfo.Kind != reflect.Struct {
+	if elemTypeInfo.Kind != reflect.Struct && elemTypeInfo.adapter == nil {
 		return errors.New("scrub: expecting struct, got " + elemTypeInfo.ToStringValue)
 	}
 
-	// Identify which properties to clear
+	// Identify which properties to clear before clearing any, rules such as 'self' read sibling fields
+	var keptBuffer [64]bool
+	kept := keptBuffer[:0]
 	for i := 0; i < len(elemTypeInfo.Field); i++ {
+		itemFieldInfo := &elemTypeInfo.Field[i]
+		kept = append(kept, !itemFieldInfo.Exported || e.allows(&elemTypeInfo, itemFieldInfo, eval, elemValue))
+	}
 
-		itemFieldInfo := elemTypeInfo.Field[i]
-		hasDefaultValue := false
-		if len(itemFieldInfo.AclTags) > 0 {
-			found := false
-
-			for _, providedAcl := range acl {
-				for _, tagAcl := range itemFieldInfo.AclTags {
-					if tagAcl == "*" || strings.EqualFold(tagAcl, providedAcl) {
-						found = true
-					}
-				}
-			}
+	for i := 0; i < len(elemTypeInfo.Field); i++ {
+
+		itemFieldInfo := &elemTypeInfo.Field[i]
+		if !itemFieldInfo.Exported {
+			continue
+		}
 
-			if !found {
-				hasDefaultValue = true
-				ev := elemValue.Field(i)
-				setToDefault(ev)
+		if !kept[i] {
+			action := ActionZero
+			if len(itemFieldInfo.Mask) > 0 && elemTypeInfo.mask(elemValue, i, itemFieldInfo.Mask) {
+				action = ActionMask
+			} else {
+				elemTypeInfo.clear(elemValue, i)
+			}
+			if observer != nil {
+				observer.Redacted(Redaction{
+					Path:   fieldPath(observer != nil, path, itemFieldInfo.Name),
+					Rule:   itemFieldInfo.rule(),
+					Action: action,
+				})
 			}
+			continue
 		}
 
 		// scrub field if we have not set it to default and it's a supports type
-		if !hasDefaultValue {
-			switch itemFieldInfo.Kind {
-			case reflect.Ptr, reflect.Array, reflect.Slice, reflect.Map:
-				ev := elemValue.Field(i)
-				Scrub(ev.Interface(), acl)
+		switch itemFieldInfo.Kind {
+		case reflect.Ptr, reflect.Array, reflect.Slice, reflect.Map:
+			ev := elemTypeInfo.value(elemValue, i)
+			if ev.Kind() == reflect.Ptr && ev.IsNil() {
+				continue
 			}
+			e.scrub(ev.Interface(), eval, observer, fieldPath(observer != nil, path, itemFieldInfo.Name))
 		}
 	}
 
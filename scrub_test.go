@@ -5,6 +5,7 @@
 package acllibgo
 
 import (
+	"strconv"
 	"testing"
 	"time"
 
@@ -258,3 +259,47 @@ func Benchmark_Scrub_MultiAcl(b *testing.B) {
 		Scrub(&testItem, []string{"access", "login"})
 	}
 }
+
+// benchmarkGroups returns count groups, the last one of which matches the Person tags
+func benchmarkGroups(count int) []string {
+	rv := make([]string, count)
+	for x := 0; x < count-1; x++ {
+		rv[x] = "org:acme:team" + strconv.Itoa(x)
+	}
+	rv[count-1] = "login"
+	return rv
+}
+
+func benchmarkScrubGroups(b *testing.B, count int) {
+	testItem := newPerson()
+	groups := benchmarkGroups(count)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		Scrub(&testItem, groups)
+	}
+}
+
+func Benchmark_Scrub_Groups1(b *testing.B) {
+	benchmarkScrubGroups(b, 1)
+}
+
+func Benchmark_Scrub_Groups10(b *testing.B) {
+	benchmarkScrubGroups(b, 10)
+}
+
+func Benchmark_Scrub_Groups100(b *testing.B) {
+	benchmarkScrubGroups(b, 100)
+}
+
+func Benchmark_Scrub_Groups100_List(b *testing.B) {
+	items := make([]*Person, 1000)
+	for x := range items {
+		item := newPerson()
+		items[x] = &item
+	}
+	groups := benchmarkGroups(100)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		Scrub(items, groups)
+	}
+}
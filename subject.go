@@ -0,0 +1,210 @@
+// Copyright 2020 Alexander Zherdev. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package acllibgo
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// Subject is the caller the item is scrubbed for
+// The zero value is an authenticated principal without groups, the state the Scrub acl []string{} stands for.
+type Subject struct {
+	// Anonymous marks a caller that is not logged in, its ID, Groups and Patterns are ignored
+	Anonymous bool
+	// ID identifies the principal, matched against the struct owner field by the 'self' rule
+	ID string
+	// Groups are matched against the 'acl' tag names, same as the Scrub acl
+	// They are compared literally, a group containing '*' only matches the same literal name.
+	Groups []string
+	// Patterns are group patterns granted to the caller, e.g. org:acme:** for a tenant administrator
+	// They match the groups of the 'acl' tags like patterns in tags do. Only set trusted patterns here,
+	// never groups taken from tokens or claims.
+	Patterns []string
+	// Attributes are caller properties available to predicates, e.g. tenant or region
+	Attributes map[string]interface{}
+	// Flags are the named flags of the call checked by the flag(name) condition, e.g. a support session
+	Flags map[string]bool
+	// Now is the clock of the after(field) and before(field) conditions, time.Now when nil
+	Now func() time.Time
+}
+
+// Predicate decides whether the subject may see the fields referring to it for the struct value obj
+// Predicates are registered by name on an Engine and referenced from 'acl' tags as @name.
+type Predicate func(subject Subject, obj reflect.Value) bool
+
+// evaluation is the state of a single traversal for a subject
+// The policy state is captured once so the whole traversal sees a single policy version.
+// The subject groups are converted once into a bitset over the group indices of the engine, so matching
+// a field is a single AND against the bitset compiled into its plan. Subject groups no rule refers to
+// yet are kept pending and looked up again when compiling a plan during the traversal adds groups.
+type evaluation struct {
+	subject    *Subject
+	state      *policyState
+	predicates map[predicateKey]bool
+	groups     bitset
+	pending    []string
+	resolved   int64
+	patterns   []*groupPattern
+	named      bool
+}
+
+type predicateKey struct {
+	addr uintptr
+	typ  reflect.Type
+	name string
+}
+
+func (e *Engine) newEvaluation(subject *Subject) *evaluation {
+	if subject.Anonymous {
+		subject = &Subject{Anonymous: true, Attributes: subject.Attributes, Flags: subject.Flags, Now: subject.Now}
+	}
+
+	eval := &evaluation{subject: subject, state: e.currentState()}
+	eval.resolved = eval.state.groups.len()
+	for _, p := range subject.Patterns {
+		eval.patterns = append(eval.patterns, compileGroupPattern(p))
+	}
+	for _, g := range subject.Groups {
+		if index, ok := eval.state.groups.lookup(g); ok {
+			eval.groups.set(index)
+			continue
+		}
+		eval.pending = append(eval.pending, g)
+	}
+	return eval
+}
+
+// groupBits returns the bitset of the subject groups, resolving pending groups the registry learnt since
+func (eval *evaluation) groupBits() bitset {
+	if len(eval.pending) == 0 {
+		return eval.groups
+	}
+
+	size := eval.state.groups.len()
+	if size == eval.resolved {
+		return eval.groups
+	}
+	eval.resolved = size
+
+	pending := eval.pending[:0]
+	for _, g := range eval.pending {
+		if index, ok := eval.state.groups.lookup(g); ok {
+			eval.groups.set(index)
+			continue
+		}
+		pending = append(pending, g)
+	}
+	eval.pending = pending
+
+	return eval.groups
+}
+
+// hasGroups reports whether the subject was provided any group or pattern, as the * keyword requires
+func (eval *evaluation) hasGroups() bool {
+	return len(eval.subject.Groups) > 0 || len(eval.patterns) > 0
+}
+
+// groupName returns the subject's spelling of the group at the index
+func (eval *evaluation) groupName(index int) string {
+	for _, g := range eval.subject.Groups {
+		if i, ok := eval.state.groups.lookup(g); ok && i == index {
+			return g
+		}
+	}
+	return eval.state.groups.name(index)
+}
+
+// typeInfo returns the type information with the captured policy applied
+func (eval *evaluation) typeInfo(t reflect.Type) typeInfo {
+	return eval.state.plan(t)
+}
+
+// predicate evaluates the named predicate for obj, results are cached per object for the evaluation
+func (eval *evaluation) predicate(e *Engine, name string, obj reflect.Value) bool {
+	if !obj.IsValid() {
+		return false
+	}
+
+	var key predicateKey
+	cacheable := obj.CanAddr()
+	if cacheable {
+		key = predicateKey{addr: obj.UnsafeAddr(), typ: obj.Type(), name: name}
+		if result, ok := eval.predicates[key]; ok {
+			return result
+		}
+	}
+
+	p, ok := e.getPredicate(name)
+	result := ok && p(*eval.subject, obj)
+
+	if cacheable {
+		if eval.predicates == nil {
+			eval.predicates = make(map[predicateKey]bool)
+		}
+		eval.predicates[key] = result
+	}
+
+	return result
+}
+
+// hasGroup reports whether the subject of the evaluation is in the group of the term
+// A pattern term requires one of the subject groups to match it.
+func (eval *evaluation) hasGroup(term aclTerm) bool {
+	if term.Pattern != nil {
+		for _, g := range eval.subject.Groups {
+			if !isGroupPattern(g) && term.Pattern.match(g) {
+				return true
+			}
+		}
+		return false
+	}
+
+	group := term.Group
+	if index, ok := eval.state.groups.lookup(group); ok && eval.groupBits().has(index) {
+		return true
+	}
+	for _, p := range eval.patterns {
+		if p.match(group) {
+			return true
+		}
+	}
+	return false
+}
+
+// isOwner reports whether the subject owns the struct value through its 'aclowner' field
+func (s *Subject) isOwner(t *typeInfo, obj reflect.Value) bool {
+	if len(s.ID) == 0 || t.OwnerField < 0 || !obj.IsValid() {
+		return false
+	}
+
+	owner, ok := formatOwner(t.value(obj, t.OwnerField))
+	return ok && owner == s.ID
+}
+
+// formatOwner renders the owner field value for comparison with the subject ID
+func formatOwner(v reflect.Value) (string, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), v.Len() > 0
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), true
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return "", false
+		}
+		return formatOwner(v.Elem())
+	}
+
+	if v.CanInterface() {
+		return fmt.Sprint(v.Interface()), true
+	}
+
+	return "", false
+}
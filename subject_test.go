@@ -0,0 +1,235 @@
+// Copyright 2020 Alexander Zherdev. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+package acllibgo
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type Member struct {
+	ID      int64     `aclowner:""`
+	Name    string    `acl:"public"`
+	Email   string    `acl:"admin,self"`
+	Friends []*Member `json:"friends"`
+}
+
+func newMembers() []*Member {
+	return []*Member{
+		{ID: 1, Name: "John", Email: "john@example.com", Friends: []*Member{
+			{ID: 2, Name: "Jane", Email: "jane@example.com"},
+		}},
+		{ID: 2, Name: "Jane", Email: "jane@example.com", Friends: []*Member{
+			{ID: 1, Name: "John", Email: "john@example.com"},
+		}},
+	}
+}
+
+func Test_ScrubSubject_Self(t *testing.T) {
+
+	members := newMembers()
+
+	err := ScrubSubject(members, Subject{ID: "2", Groups: []string{"user"}})
+	assert.NoError(t, err)
+
+	assert.Equal(t, "", members[0].Email)
+	assert.Equal(t, "jane@example.com", members[0].Friends[0].Email)
+	assert.Equal(t, "jane@example.com", members[1].Email)
+	assert.Equal(t, "", members[1].Friends[0].Email)
+	assert.Equal(t, "John", members[1].Friends[0].Name)
+}
+
+func Test_ScrubSubject_Group(t *testing.T) {
+
+	members := newMembers()
+
+	err := ScrubSubject(members, Subject{ID: "3", Groups: []string{"admin"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "john@example.com", members[0].Email)
+	assert.Equal(t, "jane@example.com", members[1].Email)
+}
+
+func Test_ScrubSubject_NoPrincipal(t *testing.T) {
+
+	type Note struct {
+		Owner string `aclowner:""`
+		Text  string `acl:"self"`
+	}
+
+	// an empty subject ID never matches an empty owner
+	testItem := Note{Text: "private"}
+	err := ScrubSubject(&testItem, Subject{Groups: []string{}})
+	assert.NoError(t, err)
+	assert.Equal(t, "", testItem.Text)
+
+	// legacy scrub has no principal
+	members := newMembers()
+	err = Scrub(members, []string{"user"})
+	assert.NoError(t, err)
+	assert.Equal(t, "", members[0].Email)
+	assert.Equal(t, "", members[1].Email)
+}
+
+func Test_ExplainSubject_Self(t *testing.T) {
+
+	members := newMembers()
+
+	explanation, err := ExplainSubject(members, Subject{ID: "1", Groups: []string{}})
+	assert.NoError(t, err)
+	assert.Contains(t, explanation, Decision{Path: "[0].Email", Kept: true, Reason: ReasonSelf, Rule: `acl:"admin,self"`, Source: SourceTag})
+	assert.Contains(t, explanation, Decision{Path: "[0].Friends[0].Email", Kept: false, Action: ActionZero, Reason: ReasonNoMatch, Rule: `acl:"admin,self"`, Source: SourceTag})
+
+	// no object to evaluate 'self' against
+	assert.Equal(t, []StructField{{Name: "ID"}, {Name: "Name"}, {Name: "Friends", Fields: nil}}, VisibleFields(reflect.TypeOf(Member{}), []string{}))
+}
+
+type Invoice struct {
+	Tenant string
+	Status string
+	Amount int    `acl:"@sameTenant|admin"`
+	Notes  string `acl:"auditor@active"`
+}
+
+func newPredicateEngine(calls *int) *Engine {
+	e := NewEngine(Options{})
+	e.RegisterPredicate("sameTenant", func(subject Subject, obj reflect.Value) bool {
+		*calls++
+		return obj.FieldByName("Tenant").String() == subject.Attributes["tenant"]
+	})
+	e.RegisterPredicate("active", func(subject Subject, obj reflect.Value) bool {
+		return obj.FieldByName("Status").String() != "archived"
+	})
+	return e
+}
+
+func Test_ScrubSubject_Predicate(t *testing.T) {
+
+	calls := 0
+	e := newPredicateEngine(&calls)
+
+	invoices := []*Invoice{
+		{Tenant: "acme", Status: "open", Amount: 10, Notes: "n1"},
+		{Tenant: "other", Status: "open", Amount: 20, Notes: "n2"},
+		{Tenant: "acme", Status: "archived", Amount: 30, Notes: "n3"},
+	}
+
+	err := e.ScrubSubject(invoices, Subject{Groups: []string{"auditor"}, Attributes: map[string]interface{}{"tenant": "acme"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 10, invoices[0].Amount)
+	assert.Equal(t, 0, invoices[1].Amount)
+	assert.Equal(t, 30, invoices[2].Amount)
+	assert.Equal(t, "n1", invoices[0].Notes)
+	assert.Equal(t, "n2", invoices[1].Notes)
+	assert.Equal(t, "", invoices[2].Notes)
+	assert.Equal(t, 3, calls)
+
+	// group restricted predicate requires the group
+	testItem := Invoice{Tenant: "acme", Status: "open", Notes: "n1"}
+	err = e.ScrubSubject(&testItem, Subject{Groups: []string{"user"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "", testItem.Notes)
+
+	// admin does not need the predicate
+	testItem = Invoice{Tenant: "acme", Status: "open", Amount: 10}
+	err = e.ScrubSubject(&testItem, Subject{Groups: []string{"admin"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 10, testItem.Amount)
+}
+
+func Test_ScrubSubject_PredicateCached(t *testing.T) {
+
+	type Document struct {
+		Tenant string
+		Title  string `acl:"@sameTenant"`
+		Body   string `acl:"@sameTenant"`
+	}
+
+	calls := 0
+	e := newPredicateEngine(&calls)
+
+	testItem := Document{Tenant: "acme", Title: "t", Body: "b"}
+	err := e.ScrubSubject(&testItem, Subject{Attributes: map[string]interface{}{"tenant": "acme"}})
+	assert.NoError(t, err)
+	assert.Equal(t, Document{Tenant: "acme", Title: "t", Body: "b"}, testItem)
+	assert.Equal(t, 1, calls)
+}
+
+func Test_ScrubSubject_UnknownPredicate(t *testing.T) {
+
+	type Document struct {
+		Title string `acl:"@missing"`
+	}
+
+	testItem := Document{Title: "t"}
+	err := ScrubSubject(&testItem, Subject{Groups: []string{"admin"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "", testItem.Title)
+}
+
+func Test_ExplainSubject_Predicate(t *testing.T) {
+
+	calls := 0
+	e := newPredicateEngine(&calls)
+
+	testItem := Invoice{Tenant: "acme", Status: "open"}
+	explanation, err := e.ExplainSubject(&testItem, Subject{Groups: []string{"auditor"}, Attributes: map[string]interface{}{"tenant": "acme"}})
+	assert.NoError(t, err)
+	assert.Contains(t, explanation, Decision{Path: "Amount", Kept: true, Reason: ReasonPredicate, Predicate: "sameTenant", Rule: `acl:"@sameTenant|admin"`, Source: SourceTag})
+	assert.Contains(t, explanation, Decision{Path: "Notes", Kept: true, Reason: ReasonPredicate, Group: "auditor", Predicate: "active", Rule: `acl:"auditor@active"`, Source: SourceTag})
+	assert.Contains(t, explanation.String(), "matched predicate auditor@active")
+}
+
+type Product struct {
+	Name     string
+	Price    int    `acl:"authenticated"`
+	Login    string `acl:"anonymous"`
+	Discount int    `acl:"*"`
+	Cost     int    `acl:"admin"`
+}
+
+func newProduct() *Product {
+	return &Product{Name: "Lamp", Price: 30, Login: "log in to see the price", Discount: 5, Cost: 12}
+}
+
+func Test_ScrubAnonymous(t *testing.T) {
+
+	item := newProduct()
+	assert.NoError(t, ScrubAnonymous(item))
+	assert.Equal(t, &Product{Name: "Lamp", Login: "log in to see the price"}, item)
+}
+
+func Test_ScrubAuthenticated_NoGroups(t *testing.T) {
+
+	for _, groups := range [][]string{nil, {}} {
+		item := newProduct()
+		assert.NoError(t, ScrubAuthenticated(item, groups))
+		assert.Equal(t, &Product{Name: "Lamp", Price: 30}, item)
+	}
+
+	item := newProduct()
+	assert.NoError(t, Scrub(item, []string{}))
+	assert.Equal(t, &Product{Name: "Lamp", Price: 30}, item)
+}
+
+func Test_ScrubAuthenticated_Groups(t *testing.T) {
+
+	item := newProduct()
+	assert.NoError(t, ScrubAuthenticated(item, []string{"admin"}))
+	assert.Equal(t, &Product{Name: "Lamp", Price: 30, Discount: 5, Cost: 12}, item)
+}
+
+func Test_ScrubSubject_AnonymousIgnoresGroups(t *testing.T) {
+
+	item := newProduct()
+	assert.NoError(t, ScrubSubject(item, Subject{Anonymous: true, ID: "1", Groups: []string{"admin"}}))
+	assert.Equal(t, &Product{Name: "Lamp", Login: "log in to see the price"}, item)
+
+	explanation, err := ExplainSubject(newProduct(), Subject{Anonymous: true})
+	assert.NoError(t, err)
+	assert.Contains(t, explanation, Decision{Path: "Login", Kept: true, Reason: ReasonAnonymous, Rule: `acl:"anonymous"`, Source: SourceTag})
+	assert.Contains(t, explanation, Decision{Path: "Discount", Kept: false, Action: ActionZero, Reason: ReasonNoMatch, Rule: `acl:"*"`, Source: SourceTag})
+}
@@ -32,6 +32,14 @@ func setToDefault(f reflect.Value) {
 	}
 }
 
+// clearDenied resets a denied field, a struct value is zeroed as a whole so none of its fields survive
+// Unlike setToDefault, used by Keep and Zero, it clears every kind.
+func clearDenied(f reflect.Value) {
+	if f.IsValid() && f.CanSet() {
+		f.Set(reflect.Zero(f.Type()))
+	}
+}
+
 func toJson(i interface{}) string {
 	j, _ := json.Marshal(i)
 	return string(j)
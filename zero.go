@@ -12,6 +12,15 @@ import (
 
 // Zero clears the value of the properties provided, other properties are untouched
 func Zero(item interface{}, fields []StructField) error {
+	return zero(item, fields, nil, "")
+}
+
+// ZeroWithObserver clears the fields like Zero and reports every cleared field to the observer
+func ZeroWithObserver(item interface{}, fields []StructField, observer Observer) error {
+	return zero(item, fields, observer, "")
+}
+
+func zero(item interface{}, fields []StructField, observer Observer, path string) error {
 	if item == nil {
 		return errors.New("fields: nil item")
 	}
@@ -34,7 +43,7 @@ func Zero(item interface{}, fields []StructField) error {
 
 		for i := 0; i < itemValue.Len(); i++ {
 			item := itemValue.Index(i)
-			Zero(item.Interface(), fields)
+			zero(item.Interface(), fields, observer, indexPath(observer != nil, path, i))
 		}
 
 		return nil
@@ -47,7 +56,7 @@ func Zero(item interface{}, fields []StructField) error {
 		for _, mKey := range itemValue.MapKeys() {
 			mapValue := itemValue.MapIndex(mKey)
 			if mapValue.Kind() == reflect.Ptr && !mapValue.IsNil() {
-				Zero(mapValue.Interface(), fields)
+				zero(mapValue.Interface(), fields, observer, keyPath(observer != nil, path, mKey))
 			}
 		}
 
@@ -76,6 +85,9 @@ func Zero(item interface{}, fields []StructField) error {
 	// Identify which properties to clear
 	for i := 0; i < len(elemTypeInfo.Field); i++ {
 		itemFieldInfo := elemTypeInfo.Field[i]
+		if !itemFieldInfo.Exported {
+			continue
+		}
 
 		remove := false
 		fieldFields := []StructField{}
@@ -93,12 +105,19 @@ func Zero(item interface{}, fields []StructField) error {
 		if remove {
 			ev := elemValue.Field(i)
 			setToDefault(ev)
+			if observer != nil {
+				observer.Redacted(Redaction{
+					Path:   fieldPath(observer != nil, path, itemFieldInfo.Name),
+					Rule:   RuleZero,
+					Action: ActionZero,
+				})
+			}
 		} else {
 			// scrub field if we have not set it to default and it's a supports type
 			switch itemFieldInfo.Kind {
 			case reflect.Ptr, reflect.Array, reflect.Slice, reflect.Map:
 				ev := elemValue.Field(i)
-				Zero(ev.Interface(), fieldFields)
+				zero(ev.Interface(), fieldFields, observer, fieldPath(observer != nil, path, itemFieldInfo.Name))
 			}
 		}
 	}